@@ -2,19 +2,50 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
 	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/health"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/idempotency"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/metrics"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/requestid"
 	"github.com/JuD4Mo/go_api_web_enrollment/pkg/bootstrap"
 	"github.com/JuD4Mo/go_api_web_enrollment/pkg/handler"
 
 	courseSDK "github.com/JuD4Mo/go_api_web_sdk/course"
 	userSDK "github.com/JuD4Mo/go_api_web_sdk/user"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultShutdownTimeout = 15 * time.Second
+	defaultHealthTimeout   = 2 * time.Second
+	defaultStatusInterval  = 30 * time.Second
+	defaultMetricsPort     = "9090"
+
+	defaultIdempotencyLease   = time.Minute
+	defaultIdempotencyTTL     = 24 * time.Hour
+	defaultIdempotencyCleanup = time.Hour
+	defaultRateLimitSweep     = time.Minute
+
+	// exitDrainTimeout is the exit code when the requests in flight did not
+	// finish within SHUTDOWN_TIMEOUT.
+	exitDrainTimeout = 3
 )
 
 func main() {
@@ -22,57 +53,290 @@ func main() {
 	//Cargamos las variables de entorno que están en el archivo .env por medio del package godotenv
 	_ = godotenv.Load()
 
-	//Instanciamos un logger propio
-	l := bootstrap.InitLogger()
+	//Instanciamos un logger estructurado, configurado con LOG_FORMAT y LOG_LEVEL
+	l, err := bootstrap.InitLogger()
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(l)
+
+	//"migrate up|down|status" administra las migraciones y termina sin levantar el servidor
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(l, os.Args[2:]); err != nil {
+			fatal(l, "migrate", err)
+		}
+		return
+	}
+
+	//Trazas de OpenTelemetry configuradas con OTEL_TRACES_EXPORTER
+	shutdownTracing, err := bootstrap.InitTracing(context.Background())
+	if err != nil {
+		fatal(l, "initializing tracing", err)
+	}
+
+	var db *gorm.DB
+	var enrollRepo enrollment.Repository
+	var idempotencyRepo idempotency.Repository
+
+	//Una clave sin respuesta pasado IDEMPOTENCY_LEASE se puede reutilizar, las respuestas se guardan por IDEMPOTENCY_TTL
+	lease := defaultIdempotencyLease
+	if t := os.Getenv("IDEMPOTENCY_LEASE"); t != "" {
+		lease, err = time.ParseDuration(t)
+		if err != nil {
+			fatal(l, "invalid idempotency lease", err)
+		}
+	}
+	ttl := defaultIdempotencyTTL
+	if t := os.Getenv("IDEMPOTENCY_TTL"); t != "" {
+		ttl, err = time.ParseDuration(t)
+		if err != nil {
+			fatal(l, "invalid idempotency ttl", err)
+		}
+	}
+
+	//Con ENROLLMENT_REPOSITORY=memory el servicio corre sin base de datos, las claves de idempotencia también quedan en memoria
+	if os.Getenv("ENROLLMENT_REPOSITORY") == "memory" {
+		enrollRepo = enrollment.NewMemoryRepo(l)
+		idempotencyRepo = idempotency.NewMemoryRepo(l, lease, ttl)
+	} else {
+		db, err = bootstrap.DBConnection(l)
+		if err != nil {
+			fatal(l, "connecting to the database", err)
+		}
+		bootstrap.CheckIndexes(db, l)
+		enrollRepo = enrollment.NewRepo(db, l)
+		idempotencyRepo = idempotency.NewRepo(db, l, lease, ttl)
+	}
+
+	//Los tokens JWT se verifican con las claves de AUTH_*, AUTH_DISABLED=true deja la API abierta
+	verifier, err := bootstrap.InitAuth()
+	if err != nil {
+		fatal(l, "initializing authentication", err)
+	}
+	if verifier == nil {
+		l.Warn("authentication is disabled, every caller can use the API")
+	}
 
-	db, err := bootstrap.DBConnection()
+	//Límite de peticiones por cliente configurado con RATE_LIMIT_*, RATE_LIMIT_DISABLED=true lo desactiva
+	limiter, err := bootstrap.InitRateLimit(l)
 	if err != nil {
-		l.Fatal(err)
+		fatal(l, "initializing rate limiting", err)
 	}
 
 	pagLimDef := os.Getenv("PAGINATOR_LIMIT_DEFAULT")
 	if pagLimDef == "" {
-		l.Fatal("paginator limit default is required")
+		fatal(l, "paginator limit default is required", nil)
 	}
 	token := os.Getenv("API_COURSE_TOKEN")
 
+	//Cupos de los cursos sin capacidad propia en course_capacities, 0 o vacío significa sin límite
+	capacity := 0
+	if c := os.Getenv("COURSE_CAPACITY"); c != "" {
+		capacity, err = strconv.Atoi(c)
+		if err != nil {
+			fatal(l, "invalid course capacity", err)
+		}
+	}
+
+	//Tamaño máximo de un lote de inscripciones, vacío usa enrollment.DefaultMaxBulkItems
+	maxBulkItems := 0
+	if b := os.Getenv("BULK_MAX_ITEMS"); b != "" {
+		maxBulkItems, err = strconv.Atoi(b)
+		if err != nil || maxBulkItems <= 0 {
+			fatal(l, "invalid bulk max items", err)
+		}
+	}
+
+	//Tiempo máximo para terminar las peticiones en curso al apagar el servidor
+	shutdownTimeout := defaultShutdownTimeout
+	if t := os.Getenv("SHUTDOWN_TIMEOUT"); t != "" {
+		shutdownTimeout, err = time.ParseDuration(t)
+		if err != nil {
+			fatal(l, "invalid shutdown timeout", err)
+		}
+	}
+
+	//Los clientes del SDK no reciben el contexto ni un http.Client propio, el X-Request-ID no llega a los servicios de usuarios y cursos
 	userTransport := userSDK.NewHttpClient(os.Getenv("API_USER_URL"), "")
 	courseTransport := courseSDK.NewHttpClient(os.Getenv("API_COURSE_URL"), token)
 
 	ctx := context.Background()
 
-	enrollRepo := enrollment.NewRepo(db, l)
-	enrollService := enrollment.NewService(l, enrollRepo, userTransport, courseTransport)
-	h := handler.NewEnrollmentHTTPServer(ctx, enrollment.MakeEndpoints(enrollService, enrollment.Config{LimitPage: pagLimDef}))
+	//Dependencias revisadas por /readyz, los servicios de usuarios y cursos solo con HEALTH_CHECK_SERVICES=true
+	var checks []health.Check
+	if db != nil {
+		checks = append(checks, health.DatabaseCheck(db))
+	}
+	if os.Getenv("HEALTH_CHECK_SERVICES") == "true" {
+		checks = append(checks, health.UserServiceCheck(userTransport), health.CourseServiceCheck(courseTransport))
+	}
+
+	healthTimeout := defaultHealthTimeout
+	if t := os.Getenv("HEALTH_CHECK_TIMEOUT"); t != "" {
+		healthTimeout, err = time.ParseDuration(t)
+		if err != nil {
+			fatal(l, "invalid health check timeout", err)
+		}
+	}
+
+	//Métricas de Prometheus expuestas en /metrics del servidor interno
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	m := metrics.New(reg)
+
+	statusInterval := defaultStatusInterval
+	if t := os.Getenv("METRICS_STATUS_INTERVAL"); t != "" {
+		statusInterval, err = time.ParseDuration(t)
+		if err != nil {
+			fatal(l, "invalid metrics status interval", err)
+		}
+	}
+
+	enrollRepo = enrollment.NewInstrumentingRepo(enrollRepo, m.RepositoryOperations, m.RepositoryLatency)
+	instrumentedUser := enrollment.NewInstrumentingUserTransport(userTransport, m.DependencyRequests, m.DependencyLatency)
+	instrumentedCourse := enrollment.NewInstrumentingCourseTransport(courseTransport, m.DependencyRequests, m.DependencyLatency)
+
+	enrollService := enrollment.NewService(l, enrollRepo, instrumentedUser, instrumentedCourse, capacity)
+	healthService := health.NewService(l, healthTimeout, checks...)
+	opts := append(handler.TracingOptions(), handler.LoggingOptions(l)...)
+	opts = append(opts, handler.InstrumentingOptions(m.HTTPRequests, m.HTTPLatency)...)
+	h := handler.NewEnrollmentHTTPServer(ctx, enrollment.MakeEndpoints(enrollService, enrollment.Config{
+		LimitPage:    pagLimDef,
+		MaxBulkItems: maxBulkItems,
+		Idempotency:  idempotencyRepo,
+		Auth:         verifier,
+		Logger:       l,
+	}), health.MakeEndpoints(healthService), opts...)
+
+	var routes http.Handler = h
+	if limiter != nil {
+		routes = limiter.Middleware(h)
+	}
+
+	//El token se verifica una sola vez por petición, el límite y los endpoints usan el resultado
+	if verifier != nil {
+		routes = auth.Middleware(verifier)(routes)
+	}
 
 	port := os.Getenv("PORT")
 	address := fmt.Sprintf("127.0.0.1:%s", port)
 
 	//Se crea una instancia de un servidor
 	srv := &http.Server{
-		Handler:      accessControl(h),
+		Handler:      requestid.Middleware(accessControl(routes)),
 		Addr:         address,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 	}
 
-	errCh := make(chan error)
+	//Las métricas se sirven en un puerto interno, METRICS_PORT, fuera de la API pública
+	metricsPort := os.Getenv("METRICS_PORT")
+	if metricsPort == "" {
+		metricsPort = defaultMetricsPort
+	}
+	if metricsPort == port {
+		fatal(l, "metrics port must differ from the API port", nil)
+	}
+	metricsRouter := http.NewServeMux()
+	metricsRouter.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	metricsSrv := &http.Server{
+		Handler:      metricsRouter,
+		Addr:         fmt.Sprintf("127.0.0.1:%s", metricsPort),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+
+	//SIGINT y SIGTERM inician el apagado del servidor
+	stop, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+
+	//Actualiza periódicamente la cantidad de inscripciones por estado
+	go enrollment.ReportStatuses(stop, l, enrollService, m.Enrollments, statusInterval)
+
+	//Descarta periódicamente los buckets sin uso del límite de peticiones
+	if limiter != nil {
+		go limiter.Cleanup(stop, defaultRateLimitSweep)
+	}
+
+	//Borra periódicamente las claves de idempotencia vencidas
+	go idempotency.Cleanup(stop, l, idempotencyRepo, defaultIdempotencyCleanup)
+
+	errCh := make(chan error, 2)
 	go func() {
-		l.Println("listen in", address)
+		l.Info("listening", "address", address)
 		errCh <- srv.ListenAndServe()
 	}()
+	go func() {
+		l.Info("serving metrics", "address", metricsSrv.Addr)
+		errCh <- metricsSrv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-errCh:
+		fatal(l, "serving", err)
+	case <-stop.Done():
+		cancel()
+	}
 
-	err = <-errCh
+	//Dejamos de aceptar conexiones y esperamos a que terminen las peticiones en curso
+	l.Info("shutting down, draining requests", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancelShutdown := context.WithTimeout(ctx, shutdownTimeout)
+	err = srv.Shutdown(shutdownCtx)
+	cancelShutdown()
+
+	exitCode := 0
 	if err != nil {
-		log.Fatal(err)
+		l.Error("shutting down", logging.Err(err))
+		_ = srv.Close()
+		if errors.Is(err, context.DeadlineExceeded) {
+			exitCode = exitDrainTimeout
+		} else {
+			exitCode = 1
+		}
+	}
+
+	//Las métricas se sirven hasta que la API terminó de drenar
+	metricsCtx, cancelMetrics := context.WithTimeout(ctx, time.Second)
+	if err := metricsSrv.Shutdown(metricsCtx); err != nil {
+		_ = metricsSrv.Close()
+	}
+	cancelMetrics()
+
+	//Cerramos el pool de conexiones una vez que no quedan peticiones usándolo
+	if db != nil {
+		if sqlDB, err := db.DB(); err == nil {
+			if err := sqlDB.Close(); err != nil {
+				l.Error("closing database", logging.Err(err))
+			}
+		}
+	}
+
+	//Enviamos las trazas pendientes antes de terminar
+	flushCtx, cancelFlush := context.WithTimeout(ctx, 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		l.Error("flushing traces", logging.Err(err))
+	}
+	cancelFlush()
+
+	l.Info("server stopped")
+	os.Exit(exitCode)
+}
+
+// fatal logs the error that keeps the service from running and exits.
+func fatal(l *slog.Logger, msg string, err error) {
+	if err != nil {
+		l.Error(msg, logging.Err(err))
+	} else {
+		l.Error(msg)
 	}
+	os.Exit(1)
 }
 
 func accessControl(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST, PATCH, OPTIONS, DELETE, HEAD")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Modified-Since,Keep-Alive,Origin,User-Agent,X-Requested-With")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST, PATCH, PUT, OPTIONS, DELETE, HEAD")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Modified-Since,Keep-Alive,Origin,User-Agent,X-Requested-With,Idempotency-Key,traceparent,tracestate,X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID,Retry-After,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/migration"
+	"github.com/JuD4Mo/go_api_web_enrollment/pkg/bootstrap"
+)
+
+const migrateUsage = "usage: migrate up|down|status"
+
+// runMigrate runs the "migrate" subcommand against the database of the
+// DATABASE_* envs.
+func runMigrate(l *slog.Logger, args []string) error {
+	if len(args) != 1 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := bootstrap.Open()
+	if err != nil {
+		return err
+	}
+
+	m := migration.NewMigrator(db, l, migration.Migrations())
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx)
+	default:
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Roles of the callers, read from the roles claim of the token.
+const (
+	RoleStudent   = "student"
+	RoleRegistrar = "registrar"
+	RoleAdmin     = "admin"
+)
+
+type (
+	// Claims are the claims of the tokens accepted by the service.
+	Claims struct {
+		jwt.RegisteredClaims
+		Roles []string `json:"roles,omitempty"`
+	}
+
+	// Verifier checks the signature and the registered claims of the bearer
+	// tokens.
+	Verifier interface {
+		Verify(token string) (*Claims, error)
+	}
+
+	// Config holds the keys of the tokens, a Secret enables HS256 and Keys
+	// enable RS256, at least one of them is required.
+	Config struct {
+		Secret []byte
+		// Keys are the RS256 public keys by key ID, a token without kid is
+		// verified with the key of empty ID or with the only key there is
+		Keys map[string]*rsa.PublicKey
+		// Issuer and Audience are checked when set
+		Issuer   string
+		Audience string
+		// Leeway is the clock skew tolerated on exp, nbf and iat
+		Leeway time.Duration
+	}
+
+	verifier struct {
+		config Config
+		parser *jwt.Parser
+	}
+
+	// verification is the result of the verification of a token made by
+	// Middleware.
+	verification struct {
+		token  string
+		claims *Claims
+		err    error
+	}
+
+	claimsKey       struct{}
+	tokenKey        struct{}
+	verificationKey struct{}
+)
+
+func NewVerifier(config Config) (Verifier, error) {
+	var methods []string
+	if len(config.Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(config.Keys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+
+	return &verifier{
+		config: config,
+		parser: jwt.NewParser(opts...),
+	}, nil
+}
+
+func (v *verifier) Verify(token string) (*Claims, error) {
+	var claims Claims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.key); err != nil {
+		return nil, ErrInvalidToken{Err: err}
+	}
+
+	if claims.Subject == "" {
+		return nil, ErrInvalidToken{Err: jwt.ErrTokenRequiredClaimMissing}
+	}
+	return &claims, nil
+}
+
+// key returns the key that verifies the token, the parser already checked
+// that its algorithm is one of the enabled ones.
+func (v *verifier) key(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+		return v.config.Secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.config.Keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(v.config.Keys) == 1 {
+		for _, key := range v.config.Keys {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey{KeyId: kid}
+}
+
+// HasRole reports whether the caller has any of the roles.
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(c.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPToContext stores the bearer token of the Authorization header in the
+// context, meant to be used as a go-kit ServerBefore function.
+func HTTPToContext(ctx context.Context, r *http.Request) context.Context {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ctx
+	}
+	return context.WithValue(ctx, tokenKey{}, strings.TrimSpace(token))
+}
+
+// TokenFromContext returns the bearer token stored by HTTPToContext.
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
+// Middleware verifies the bearer token of the request once, ahead of the rate
+// limiter and the endpoints, and stores the result in the request context for
+// VerifyContext and VerifiedSubject.
+func Middleware(verifier Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := TokenFromContext(HTTPToContext(r.Context(), r))
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := verifier.Verify(token)
+			ctx := context.WithValue(r.Context(), verificationKey{}, verification{token: token, claims: claims, err: err})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// VerifyContext returns the result of the verification of token made by
+// Middleware, and verifies it with verifier when the request did not go
+// through Middleware.
+func VerifyContext(ctx context.Context, verifier Verifier, token string) (*Claims, error) {
+	if v, ok := ctx.Value(verificationKey{}).(verification); ok && v.token == token {
+		return v.claims, v.err
+	}
+	return verifier.Verify(token)
+}
+
+// VerifiedSubject returns the subject of the token verified by Middleware,
+// empty when the request has no valid token.
+func VerifiedSubject(ctx context.Context) string {
+	if v, ok := ctx.Value(verificationKey{}).(verification); ok && v.err == nil {
+		return v.claims.Subject
+	}
+	return ""
+}
+
+// NewContext returns a copy of ctx carrying the verified claims.
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the verified claims of the request, if any.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// Subject returns the subject of the verified claims of the request, empty
+// when the request was not authenticated.
+func Subject(ctx context.Context) string {
+	if claims, ok := FromContext(ctx); ok {
+		return claims.Subject
+	}
+	return ""
+}
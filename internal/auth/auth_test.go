@@ -0,0 +1,249 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var secret = []byte("test-secret")
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "u1",
+		"iss": "users",
+		"aud": "enrollment",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, err = auth.NewVerifier(auth.Config{})
+	assert.ErrorIs(t, err, auth.ErrNoKeys)
+
+	verifier, err := auth.NewVerifier(auth.Config{
+		Secret:   secret,
+		Keys:     map[string]*rsa.PublicKey{"k1": &rsaKey.PublicKey},
+		Issuer:   "users",
+		Audience: "enrollment",
+	})
+	require.NoError(t, err)
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	noExp := validClaims()
+	delete(noExp, "exp")
+
+	noSub := validClaims()
+	delete(noSub, "sub")
+
+	otherIssuer := validClaims()
+	otherIssuer["iss"] = "someone"
+
+	otherAudience := validClaims()
+	otherAudience["aud"] = "courses"
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "HS256", token: sign(t, jwt.SigningMethodHS256, secret, "", validClaims())},
+		{name: "RS256", token: sign(t, jwt.SigningMethodRS256, rsaKey, "k1", validClaims())},
+		{name: "wrong secret", token: sign(t, jwt.SigningMethodHS256, []byte("other"), "", validClaims()), err: jwt.ErrTokenSignatureInvalid},
+		{name: "wrong key", token: sign(t, jwt.SigningMethodRS256, otherKey, "k1", validClaims()), err: jwt.ErrTokenSignatureInvalid},
+		{name: "unknown kid", token: sign(t, jwt.SigningMethodRS256, rsaKey, "k2", validClaims()), err: auth.ErrUnknownKey{KeyId: "k2"}},
+		{name: "not enabled algorithm", token: sign(t, jwt.SigningMethodHS384, secret, "", validClaims()), err: jwt.ErrTokenSignatureInvalid},
+		{name: "none algorithm", token: sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims()), err: jwt.ErrTokenSignatureInvalid},
+		{name: "expired", token: sign(t, jwt.SigningMethodHS256, secret, "", expired), err: jwt.ErrTokenExpired},
+		{name: "without expiration", token: sign(t, jwt.SigningMethodHS256, secret, "", noExp), err: jwt.ErrTokenRequiredClaimMissing},
+		{name: "without subject", token: sign(t, jwt.SigningMethodHS256, secret, "", noSub), err: jwt.ErrTokenRequiredClaimMissing},
+		{name: "other issuer", token: sign(t, jwt.SigningMethodHS256, secret, "", otherIssuer), err: jwt.ErrTokenInvalidIssuer},
+		{name: "other audience", token: sign(t, jwt.SigningMethodHS256, secret, "", otherAudience), err: jwt.ErrTokenInvalidAudience},
+		{name: "malformed", token: "not-a-token", err: jwt.ErrTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token)
+			if tt.err == nil {
+				require.NoError(t, err)
+				assert.Equal(t, "u1", claims.Subject)
+				return
+			}
+
+			assert.Nil(t, claims)
+			assert.ErrorAs(t, err, &auth.ErrInvalidToken{})
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestVerifier_AlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// a HS256 token signed with the public key must not pass as RS256
+	verifier, err := auth.NewVerifier(auth.Config{Keys: map[string]*rsa.PublicKey{"": &rsaKey.PublicKey}})
+	require.NoError(t, err)
+
+	token := sign(t, jwt.SigningMethodHS256, rsaKey.PublicKey.N.Bytes(), "", validClaims())
+	_, err = verifier.Verify(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "", validClaims()))
+	assert.NoError(t, err)
+}
+
+func TestHTTPToContext(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{header: "Bearer abc", expected: "abc"},
+		{header: "bearer abc", expected: "abc"},
+		{header: "Basic abc"},
+		{header: "abc"},
+		{},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/enrollments", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		ctx := auth.HTTPToContext(context.Background(), r)
+		assert.Equal(t, tt.expected, auth.TokenFromContext(ctx), tt.header)
+	}
+}
+
+func TestContext(t *testing.T) {
+	assert.Empty(t, auth.Subject(context.Background()))
+
+	ctx := auth.NewContext(context.Background(), &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u1"}})
+	assert.Equal(t, "u1", auth.Subject(ctx))
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls int
+	verifier, err := auth.NewVerifier(auth.Config{Secret: secret})
+	require.NoError(t, err)
+	counting := &verifierMock{
+		VerifyMock: func(token string) (*auth.Claims, error) {
+			calls++
+			return verifier.Verify(token)
+		},
+	}
+
+	serve := func(token string) (subject string, claims *auth.Claims, err error) {
+		r := httptest.NewRequest(http.MethodGet, "/enrollments", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		auth.Middleware(counting)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject = auth.VerifiedSubject(r.Context())
+			claims, err = auth.VerifyContext(r.Context(), counting, token)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		return subject, claims, err
+	}
+
+	t.Run("verifies the token once", func(t *testing.T) {
+		calls = 0
+		subject, claims, err := serve(sign(t, jwt.SigningMethodHS256, secret, "", validClaims()))
+		require.NoError(t, err)
+		assert.Equal(t, "u1", subject)
+		assert.Equal(t, "u1", claims.Subject)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("keeps the error of an invalid token", func(t *testing.T) {
+		calls = 0
+		subject, _, err := serve(sign(t, jwt.SigningMethodHS256, []byte("other-secret"), "", validClaims()))
+		assert.ErrorAs(t, err, &auth.ErrInvalidToken{})
+		assert.Empty(t, subject)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("verifies a token the middleware did not see", func(t *testing.T) {
+		calls = 0
+		claims, err := auth.VerifyContext(context.Background(), counting, sign(t, jwt.SigningMethodHS256, secret, "", validClaims()))
+		require.NoError(t, err)
+		assert.Equal(t, "u1", claims.Subject)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+type verifierMock struct {
+	VerifyMock func(token string) (*auth.Claims, error)
+}
+
+func (v *verifierMock) Verify(token string) (*auth.Claims, error) {
+	return v.VerifyMock(token)
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	n := base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())
+
+	t.Run("reads the RS256 signing keys", func(t *testing.T) {
+		keys, err := auth.ParseJWKS([]byte(fmt.Sprintf(`{"keys":[
+			{"kty":"RSA","kid":"k1","use":"sig","alg":"RS256","n":"%s","e":"%s"},
+			{"kty":"RSA","kid":"k2","n":"%s","e":"%s"},
+			{"kty":"RSA","kid":"enc","use":"enc","n":"%s","e":"%s"},
+			{"kty":"EC","kid":"ec","crv":"P-256","x":"x","y":"y"}
+		]}`, n, e, n, e, n, e)))
+		require.NoError(t, err)
+
+		assert.Len(t, keys, 2)
+		assert.True(t, rsaKey.PublicKey.Equal(keys["k1"]))
+		assert.True(t, rsaKey.PublicKey.Equal(keys["k2"]))
+	})
+
+	t.Run("fails without signing keys", func(t *testing.T) {
+		_, err := auth.ParseJWKS([]byte(`{"keys":[]}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("fails with an invalid key", func(t *testing.T) {
+		_, err := auth.ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"k1","n":"!","e":"AQAB"}]}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("fails with invalid json", func(t *testing.T) {
+		_, err := auth.ParseJWKS([]byte(`{`))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoKeys = errors.New("a secret or a public key is required to verify the tokens")
+var ErrMissingToken = errors.New("missing bearer token")
+
+// ErrTokenRejected is answered for every token that fails the verification,
+// the reason is only logged so callers can not probe the verifier.
+var ErrTokenRejected = errors.New("invalid token")
+
+type ErrInvalidToken struct {
+	Err error
+}
+
+type ErrUnknownKey struct {
+	KeyId string
+}
+
+func (e ErrInvalidToken) Error() string {
+	return fmt.Sprintf("invalid token: %s", e.Err)
+}
+
+func (e ErrInvalidToken) Unwrap() error {
+	return e.Err
+}
+
+func (e ErrUnknownKey) Error() string {
+	return fmt.Sprintf("unknown key '%s'", e.KeyId)
+}
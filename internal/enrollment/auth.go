@@ -0,0 +1,36 @@
+package enrollment
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"github.com/JuD4Mo/go_lib_response/response"
+)
+
+// authenticate verifies the bearer token of the request before calling next,
+// which gets the verified claims in its context. Requests are not
+// authenticated when verifier is nil.
+func authenticate(log *slog.Logger, verifier auth.Verifier, next Controller) Controller {
+	if verifier == nil {
+		return next
+	}
+
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		token := auth.TokenFromContext(ctx)
+		if token == "" {
+			return nil, response.Unauthorized(auth.ErrMissingToken.Error())
+		}
+
+		claims, err := auth.VerifyContext(ctx, verifier, token)
+		if err != nil {
+			log.InfoContext(ctx, "token rejected", logging.Err(err))
+			return nil, response.Unauthorized(auth.ErrTokenRejected.Error())
+		}
+
+		ctx = auth.NewContext(ctx, claims)
+		ctx = logging.With(ctx, logging.SubjectKey, claims.Subject)
+		return next(ctx, request)
+	}
+}
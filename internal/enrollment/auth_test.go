@@ -0,0 +1,99 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_lib_response/response"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type verifierMock struct {
+	VerifyMock func(token string) (*auth.Claims, error)
+}
+
+func (v *verifierMock) Verify(token string) (*auth.Claims, error) {
+	return v.VerifyMock(token)
+}
+
+// tokenContext returns the context built by the HTTP server for a request
+// with the Authorization header.
+func tokenContext(authorization string) context.Context {
+	r := httptest.NewRequest(http.MethodGet, "/enrollments", nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	return auth.HTTPToContext(context.Background(), r)
+}
+
+func TestEndpointsAuthentication(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	var subject string
+	service := enrollment.NewService(l, &mockRepository{
+		GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+			subject = auth.Subject(ctx)
+			return &domain.Enrollment{ID: id}, nil
+		},
+	}, nil, nil, 0)
+
+	verifier := &verifierMock{
+		VerifyMock: func(token string) (*auth.Claims, error) {
+			if token != "valid" {
+				return nil, auth.ErrInvalidToken{Err: errors.New("token is expired")}
+			}
+			return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u1"}, Roles: []string{auth.RoleAdmin}}, nil
+		},
+	}
+	endpoints := enrollment.MakeEndpoints(service, enrollment.Config{Auth: verifier})
+
+	t.Run("should return unauthorized without a token", func(t *testing.T) {
+		_, err := endpoints.Get(tokenContext(""), enrollment.GetReq{ID: "20"})
+		require.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+		assert.EqualError(t, auth.ErrMissingToken, resp.Error())
+	})
+
+	t.Run("should return unauthorized with an invalid token", func(t *testing.T) {
+		_, err := endpoints.Get(tokenContext("Bearer expired"), enrollment.GetReq{ID: "20"})
+		require.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+		assert.EqualError(t, auth.ErrTokenRejected, resp.Error())
+	})
+
+	t.Run("should reject the other endpoints without a token", func(t *testing.T) {
+		for name, call := range map[string]func() (interface{}, error){
+			"create":      func() (interface{}, error) { return endpoints.Create(tokenContext(""), enrollment.CreateReq{}) },
+			"create bulk": func() (interface{}, error) { return endpoints.CreateBulk(tokenContext(""), enrollment.CreateBulkReq{}) },
+			"get all":     func() (interface{}, error) { return endpoints.GetAll(tokenContext(""), enrollment.GetAllReq{}) },
+			"update":      func() (interface{}, error) { return endpoints.Update(tokenContext(""), enrollment.UpdateReq{}) },
+			"delete":      func() (interface{}, error) { return endpoints.Delete(tokenContext(""), enrollment.DeleteReq{}) },
+			"waitlist":    func() (interface{}, error) { return endpoints.Waitlist(tokenContext(""), enrollment.WaitlistReq{}) },
+		} {
+			_, err := call()
+			require.Error(t, err, name)
+			assert.Equal(t, http.StatusUnauthorized, err.(response.Response).StatusCode(), name)
+		}
+	})
+
+	t.Run("should pass the subject to the service", func(t *testing.T) {
+		resp, err := endpoints.Get(tokenContext("Bearer valid"), enrollment.GetReq{ID: "20"})
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+		assert.Equal(t, "u1", subject)
+	})
+}
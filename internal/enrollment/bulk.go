@@ -0,0 +1,235 @@
+package enrollment
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	courseSDK "github.com/JuD4Mo/go_api_web_sdk/course"
+	userSDK "github.com/JuD4Mo/go_api_web_sdk/user"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	BulkCreated    = "created"
+	BulkWaitlisted = "waitlisted"
+	BulkInvalid    = "invalid"
+	BulkNotFound   = "not_found"
+	BulkDuplicate  = "duplicate"
+	BulkFailed     = "error"
+	BulkRolledBack = "rolled_back"
+)
+
+// DefaultMaxBulkItems is the largest batch the bulk endpoint accepts when
+// Config.MaxBulkItems is not set, big enough for a cohort. An atomic batch
+// holds its transaction and course locks until every item is inserted.
+const DefaultMaxBulkItems = 1000
+
+// bulkLookups is the number of users or courses looked up at the same time.
+const bulkLookups = 8
+
+type (
+	BulkItem struct {
+		UserId   string
+		CourseId string
+	}
+
+	BulkResult struct {
+		UserId     string             `json:"user_id"`
+		CourseId   string             `json:"course_id"`
+		Result     string             `json:"result"`
+		Enrollment *domain.Enrollment `json:"enrollment,omitempty"`
+		Err        string             `json:"error,omitempty"`
+	}
+)
+
+// CreateBulk enrolls every item and reports the outcome of each one. Items are
+// independent unless atomic is set, in which case they are inserted in a single
+// transaction and a failure in any item rolls back the whole batch, returning
+// ErrBulkRolledBack along with the results. The size of the batch is limited
+// by the caller.
+func (s service) CreateBulk(ctx context.Context, items []BulkItem, atomic bool) (_ []BulkResult, err error) {
+	ctx, span := startSpan(ctx, "enrollment.CreateBulk", attribute.Int("enrollment.items", len(items)))
+	defer func() { endSpan(span, err) }()
+
+	results := make([]BulkResult, len(items))
+	userIds := make(map[string]bool)
+	courseIds := make(map[string]bool)
+
+	for i, item := range items {
+		results[i] = BulkResult{UserId: item.UserId, CourseId: item.CourseId}
+
+		if item.UserId == "" || item.CourseId == "" {
+			results[i].Result = BulkInvalid
+			if item.UserId == "" {
+				results[i].Err = ErrUserIdRequired.Error()
+			} else {
+				results[i].Err = ErrCourseIdRequired.Error()
+			}
+			continue
+		}
+		userIds[item.UserId], courseIds[item.CourseId] = true, true
+	}
+
+	// Users and courses are looked up once per batch, cohorts usually share
+	// the course, and before any transaction is opened
+	users := lookup(ctx, userIds, func(ctx context.Context, id string) error {
+		_, err := s.getUser(ctx, id)
+		return err
+	})
+	courses := lookup(ctx, courseIds, func(ctx context.Context, id string) error {
+		_, err := s.getCourse(ctx, id)
+		return err
+	})
+
+	for i, item := range items {
+		if results[i].Result != "" {
+			continue
+		}
+
+		err := users[item.UserId]
+		if err == nil {
+			err = courses[item.CourseId]
+		}
+
+		if err != nil {
+			s.log.WarnContext(ctx, "bulk item rejected",
+				logging.UserIDKey, item.UserId, logging.CourseIDKey, item.CourseId, logging.Err(err))
+			results[i].Result, results[i].Err = bulkResult(err), err.Error()
+		}
+	}
+
+	if !atomic {
+		for i := range results {
+			if results[i].Result != "" {
+				continue
+			}
+
+			err := s.repo.Transaction(ctx, func(repo Repository) error {
+				return s.insertBulkItem(ctx, repo, &results[i])
+			})
+			if err != nil {
+				results[i].Result, results[i].Err = bulkResult(err), err.Error()
+				results[i].Enrollment = nil
+			}
+		}
+		return results, nil
+	}
+
+	err = s.repo.Transaction(ctx, func(repo Repository) error {
+		// the courses are locked up front and in the same order by every
+		// batch, two batches locking them item by item could deadlock
+		if err := lockCourses(ctx, repo, results); err != nil {
+			return err
+		}
+
+		failed := false
+		for i := range results {
+			if results[i].Result != "" {
+				failed = true
+				continue
+			}
+
+			if err := s.insertBulkItem(ctx, repo, &results[i]); err != nil {
+				results[i].Result, results[i].Err = bulkResult(err), err.Error()
+				failed = true
+			}
+		}
+
+		if failed {
+			return ErrBulkRolledBack
+		}
+		return nil
+	})
+	if err != nil {
+		for i := range results {
+			if results[i].Result == BulkCreated || results[i].Result == BulkWaitlisted {
+				results[i].Result, results[i].Enrollment = BulkRolledBack, nil
+			}
+		}
+		return results, err
+	}
+
+	return results, nil
+}
+
+func (s service) insertBulkItem(ctx context.Context, repo Repository, result *BulkResult) error {
+	enroll := &domain.Enrollment{
+		UserID:   result.UserId,
+		CourseID: result.CourseId,
+		Status:   domain.Pending,
+	}
+
+	if err := s.insert(ctx, repo, enroll); err != nil {
+		return err
+	}
+
+	result.Result, result.Enrollment = BulkCreated, enroll
+	if enroll.Status == Waitlisted {
+		result.Result = BulkWaitlisted
+	}
+	return nil
+}
+
+// lockCourses locks the courses of the pending results in ascending order.
+func lockCourses(ctx context.Context, repo Repository, results []BulkResult) error {
+	seen := make(map[string]bool)
+	var courseIds []string
+	for _, result := range results {
+		if result.Result != "" || seen[result.CourseId] {
+			continue
+		}
+		seen[result.CourseId] = true
+		courseIds = append(courseIds, result.CourseId)
+	}
+	sort.Strings(courseIds)
+
+	for _, courseId := range courseIds {
+		if err := repo.LockCourse(ctx, courseId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup calls fn for every id, at most bulkLookups at a time, and returns the
+// error of each id.
+func lookup(ctx context.Context, ids map[string]bool, fn func(ctx context.Context, id string) error) map[string]error {
+	errs := make(map[string]error, len(ids))
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, bulkLookups)
+	)
+	for id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := fn(ctx, id)
+			mu.Lock()
+			errs[id] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func bulkResult(err error) string {
+	switch {
+	case errors.As(err, &userSDK.ErrNotFound{}) || errors.As(err, &courseSDK.ErrNotFound{}):
+		return BulkNotFound
+	case errors.As(err, &ErrAlreadyEnrolled{}):
+		return BulkDuplicate
+	default:
+		return BulkFailed
+	}
+}
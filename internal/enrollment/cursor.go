@@ -0,0 +1,51 @@
+package enrollment
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+)
+
+// MaxCursorLimit is the largest page a cursor request can ask for, larger
+// limits are lowered to it.
+const MaxCursorLimit = 100
+
+type (
+	// Cursor points to the last enrollment of a page, the next page starts
+	// right after it in created_at desc, id desc order.
+	Cursor struct {
+		CreatedAt time.Time `json:"c"`
+		ID        string    `json:"i"`
+	}
+
+	CursorPage struct {
+		Enrollments []domain.Enrollment `json:"enrollments"`
+		NextCursor  string              `json:"next_cursor,omitempty"`
+	}
+)
+
+func encodeCursor(enroll domain.Enrollment) string {
+	c := Cursor{ID: enroll.ID}
+	if enroll.CreatedAt != nil {
+		c.CreatedAt = *enroll.CreatedAt
+	}
+
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
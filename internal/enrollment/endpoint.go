@@ -2,9 +2,18 @@ package enrollment
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/idempotency"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
 	"github.com/JuD4Mo/go_api_web_meta/meta"
 	courseSDK "github.com/JuD4Mo/go_api_web_sdk/course"
 	userSDK "github.com/JuD4Mo/go_api_web_sdk/user"
@@ -15,21 +24,42 @@ type (
 	Controller func(ctx context.Context, request interface{}) (response interface{}, err error)
 
 	Endpoints struct {
-		Create Controller
-		GetAll Controller
-		Update Controller
+		Create      Controller
+		CreateBulk  Controller
+		GetAll      Controller
+		Get         Controller
+		Update      Controller
+		Delete      Controller
+		Waitlist    Controller
+		SetCapacity Controller
 	}
 
 	CreateReq struct {
-		UserId   string `json:"user_id"`
-		CourseId string `json:"course_id"`
+		UserId         string `json:"user_id"`
+		CourseId       string `json:"course_id"`
+		IdempotencyKey string `json:"-"`
+	}
+
+	CreateBulkReq struct {
+		Items  []CreateReq `json:"items"`
+		Atomic bool        `json:"atomic"`
 	}
 
 	GetAllReq struct {
-		UserID   string
-		CourseID string
-		Limit    int
-		Page     int
+		UserIDs        []string
+		CourseIDs      []string
+		Statuses       []string
+		CreatedFrom    *time.Time
+		CreatedTo      *time.Time
+		IncludeDeleted bool
+		Sort           string
+		Cursor         *string // enables keyset pagination, empty asks for the first page
+		Limit          int
+		Page           int
+	}
+
+	GetReq struct {
+		ID string
 	}
 
 	UpdateReq struct {
@@ -37,6 +67,19 @@ type (
 		Status *string `json:"status"`
 	}
 
+	DeleteReq struct {
+		ID string
+	}
+
+	WaitlistReq struct {
+		CourseID string
+	}
+
+	SetCapacityReq struct {
+		CourseID string `json:"course_id"`
+		Seats    int    `json:"seats"`
+	}
+
 	Response struct {
 		Status int         `json:"status"`
 		Data   interface{} `json:"data,omitempty"`
@@ -44,20 +87,51 @@ type (
 		Meta   *meta.Meta  `json:"meta,omitempty"`
 	}
 
+	ConflictResponse struct {
+		Status  int         `json:"status"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data,omitempty"`
+	}
+
 	Config struct {
 		LimitPage string
+		// MaxBulkItems is the largest batch of the bulk endpoint,
+		// DefaultMaxBulkItems when zero
+		MaxBulkItems int
+		// Idempotency stores the Idempotency-Key of create requests, it is
+		// disabled when nil
+		Idempotency idempotency.Repository
+		// Auth verifies the bearer token of every request, requests are not
+		// authenticated when nil
+		Auth auth.Verifier
+		// Logger logs the rejected and failed requests, nothing is logged
+		// when nil
+		Logger *slog.Logger
 	}
 )
 
 func MakeEndpoints(s Service, config Config) Endpoints {
+	getId := func(request interface{}) string { return request.(GetReq).ID }
+	deleteId := func(request interface{}) string { return request.(DeleteReq).ID }
+
+	log := config.Logger
+	if log == nil {
+		log = slog.New(slog.DiscardHandler)
+	}
+
 	return Endpoints{
-		Create: makeCreateEndpoint(s),
-		GetAll: makeGetAllEndpoint(s, config),
-		Update: makeUpdateEndpoint(s),
+		Create:      authenticate(log, config.Auth, authorize(log, createPolicy, idempotentCreate(log, config.Idempotency, makeCreateEndpoint(s, log)))),
+		CreateBulk:  authenticate(log, config.Auth, authorize(log, createBulkPolicy, makeCreateBulkEndpoint(s, config))),
+		GetAll:      authenticate(log, config.Auth, authorize(log, getAllPolicy, makeGetAllEndpoint(s, config))),
+		Get:         authenticate(log, config.Auth, authorize(log, ownEnrollmentPolicy(s, getId), makeGetEndpoint(s))),
+		Update:      authenticate(log, config.Auth, authorize(log, updatePolicy(s), makeUpdateEndpoint(s))),
+		Delete:      authenticate(log, config.Auth, authorize(log, ownEnrollmentPolicy(s, deleteId), makeDeleteEndpoint(s))),
+		Waitlist:    authenticate(log, config.Auth, authorize(log, staffPolicy, makeWaitlistEndpoint(s))),
+		SetCapacity: authenticate(log, config.Auth, authorize(log, staffPolicy, makeSetCapacityEndpoint(s))),
 	}
 }
 
-func makeCreateEndpoint(s Service) Controller {
+func makeCreateEndpoint(s Service, log *slog.Logger) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(CreateReq)
 
@@ -69,28 +143,96 @@ func makeCreateEndpoint(s Service) Controller {
 			return nil, response.BadRequest(ErrCourseIdRequired.Error())
 		}
 
-		enroll, err := s.Create(ctx, req.UserId, req.CourseId)
+		enroll, position, err := s.Create(ctx, req.UserId, req.CourseId)
 		if err != nil {
-			log.Println(err)
+			log.WarnContext(ctx, "creating enrollment", logging.Err(err))
 			if errors.As(err, &userSDK.ErrNotFound{}) ||
 				errors.As(err, &courseSDK.ErrNotFound{}) {
 				return nil, response.NotFound(err.Error())
 			}
 
+			var errEnrolled ErrAlreadyEnrolled
+			if errors.As(err, &errEnrolled) {
+				return nil, conflict(err.Error(), map[string]string{"id": errEnrolled.EnrollmentId})
+			}
+
 			return nil, response.InternalServerError(err.Error())
 		}
 
+		if enroll.Status == Waitlisted {
+			return response.Accepted("waitlisted", WaitlistEntry{Position: position, Enrollment: *enroll}, nil), nil
+		}
+
 		return response.Created("success", enroll, nil), nil
 	}
 }
 
+func makeCreateBulkEndpoint(s Service, config Config) Controller {
+	maxItems := config.MaxBulkItems
+	if maxItems <= 0 {
+		maxItems = DefaultMaxBulkItems
+	}
+
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateBulkReq)
+
+		if len(req.Items) == 0 {
+			return nil, response.BadRequest(ErrItemsRequired.Error())
+		}
+
+		if len(req.Items) > maxItems {
+			return nil, response.BadRequest(ErrTooManyItems{Items: len(req.Items), Max: maxItems}.Error())
+		}
+
+		items := make([]BulkItem, len(req.Items))
+		for i, item := range req.Items {
+			items[i] = BulkItem{UserId: item.UserId, CourseId: item.CourseId}
+		}
+
+		results, err := s.CreateBulk(ctx, items, req.Atomic)
+		if err != nil {
+			if errors.Is(err, ErrBulkRolledBack) {
+				return nil, conflict(err.Error(), results)
+			}
+
+			return nil, response.InternalServerError(err.Error())
+		}
+
+		return response.OK("success", results, nil), nil
+	}
+}
+
 func makeGetAllEndpoint(s Service, config Config) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(GetAllReq)
 
+		for _, status := range req.Statuses {
+			if !validStatus(domain.EnrollStatus(status)) {
+				return nil, response.BadRequest(ErrInvalidStatus{status}.Error())
+			}
+		}
+
+		if req.CreatedFrom != nil && req.CreatedTo != nil && req.CreatedFrom.After(*req.CreatedTo) {
+			return nil, response.BadRequest(ErrInvalidDateRange.Error())
+		}
+
+		sort, err := parseSort(req.Sort)
+		if err != nil {
+			return nil, response.BadRequest(err.Error())
+		}
+
 		filters := Filters{
-			UserId:   req.UserID,
-			CourseId: req.CourseID,
+			UserIds:        req.UserIDs,
+			CourseIds:      req.CourseIDs,
+			Statuses:       req.Statuses,
+			CreatedFrom:    req.CreatedFrom,
+			CreatedTo:      req.CreatedTo,
+			IncludeDeleted: req.IncludeDeleted,
+			Sort:           sort,
+		}
+
+		if req.Cursor != nil {
+			return getAllByCursor(ctx, s, config, filters, *req.Cursor, req.Limit)
 		}
 
 		count, err := s.Count(ctx, filters)
@@ -112,6 +254,92 @@ func makeGetAllEndpoint(s Service, config Config) Controller {
 	}
 }
 
+// getAllByCursor returns a page of enrollments after the cursor without
+// counting the total, fetching one extra row to know if there is a next page.
+func getAllByCursor(ctx context.Context, s Service, config Config, filters Filters, cursor string, limit int) (interface{}, error) {
+	if len(filters.Sort) > 0 {
+		return nil, response.BadRequest(ErrCursorSort.Error())
+	}
+
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, response.BadRequest(err.Error())
+		}
+		filters.After = after
+	}
+
+	if limit <= 0 {
+		var err error
+		limit, err = strconv.Atoi(config.LimitPage)
+		if err != nil {
+			return nil, response.InternalServerError(err.Error())
+		}
+	}
+	limit = min(limit, MaxCursorLimit)
+
+	enrollments, err := s.GetAll(ctx, filters, 0, limit+1)
+	if err != nil {
+		return nil, response.InternalServerError(err.Error())
+	}
+
+	page := CursorPage{Enrollments: enrollments}
+	if len(enrollments) > limit {
+		page.Enrollments = enrollments[:limit]
+		page.NextCursor = encodeCursor(enrollments[limit-1])
+	}
+
+	return response.OK("success", page, nil), nil
+}
+
+// sortableColumns are the columns GET /enrollments can be sorted by.
+var sortableColumns = map[string]bool{
+	"id":         true,
+	"user_id":    true,
+	"course_id":  true,
+	"status":     true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// parseSort reads a comma separated list of columns, a leading '-' sorts the
+// column in descending order.
+func parseSort(sort string) ([]SortField, error) {
+	if sort == "" {
+		return nil, nil
+	}
+
+	var fields []SortField
+	for _, item := range strings.Split(sort, ",") {
+		item = strings.TrimSpace(item)
+		field := SortField{Column: strings.TrimPrefix(item, "-"), Desc: strings.HasPrefix(item, "-")}
+
+		if !sortableColumns[field.Column] {
+			return nil, ErrInvalidSort{Field: item}
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func makeGetEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetReq)
+
+		enroll, err := s.Get(ctx, req.ID)
+		if err != nil {
+			if errors.As(err, &ErrNotFound{}) {
+				return nil, response.NotFound(err.Error())
+			}
+
+			return nil, response.InternalServerError(err.Error())
+		}
+
+		return response.OK("success", enroll, nil), nil
+	}
+}
+
 func makeUpdateEndpoint(s Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(UpdateReq)
@@ -130,9 +358,101 @@ func makeUpdateEndpoint(s Service) Controller {
 				return nil, response.BadRequest(err.Error())
 			}
 
+			if errors.As(err, &ErrInvalidTransition{}) ||
+				errors.As(err, &ErrStatusConflict{}) {
+				return nil, conflict(err.Error(), nil)
+			}
+
 			return nil, response.InternalServerError(err.Error())
 		}
 
 		return response.OK("success", nil, nil), nil
 	}
 }
+
+func makeDeleteEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteReq)
+
+		if err := s.Delete(ctx, req.ID); err != nil {
+
+			if errors.As(err, &ErrNotFound{}) {
+				return nil, response.NotFound(err.Error())
+			}
+
+			if errors.As(err, &ErrInvalidTransition{}) ||
+				errors.As(err, &ErrStatusConflict{}) {
+				return nil, conflict(err.Error(), nil)
+			}
+
+			return nil, response.InternalServerError(err.Error())
+		}
+
+		return response.OK("success", nil, nil), nil
+	}
+}
+
+func makeWaitlistEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WaitlistReq)
+
+		if req.CourseID == "" {
+			return nil, response.BadRequest(ErrCourseIdRequired.Error())
+		}
+
+		entries, err := s.Waitlist(ctx, req.CourseID)
+		if err != nil {
+			return nil, response.InternalServerError(err.Error())
+		}
+
+		return response.OK("success", entries, nil), nil
+	}
+}
+
+func makeSetCapacityEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SetCapacityReq)
+
+		if req.CourseID == "" {
+			return nil, response.BadRequest(ErrCourseIdRequired.Error())
+		}
+
+		if err := s.SetCapacity(ctx, req.CourseID, req.Seats); err != nil {
+			if errors.Is(err, ErrInvalidSeats) {
+				return nil, response.BadRequest(err.Error())
+			}
+
+			if errors.As(err, &courseSDK.ErrNotFound{}) {
+				return nil, response.NotFound(err.Error())
+			}
+
+			return nil, response.InternalServerError(err.Error())
+		}
+
+		return response.OK("success", req, nil), nil
+	}
+}
+
+func conflict(msg string, data interface{}) response.Response {
+	return &ConflictResponse{
+		Status:  http.StatusConflict,
+		Message: msg,
+		Data:    data,
+	}
+}
+
+func (c *ConflictResponse) Error() string {
+	return c.Message
+}
+
+func (c *ConflictResponse) StatusCode() int {
+	return c.Status
+}
+
+func (c *ConflictResponse) GetBody() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+func (c *ConflictResponse) GetData() interface{} {
+	return c.Data
+}
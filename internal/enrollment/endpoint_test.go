@@ -3,13 +3,15 @@ package enrollment_test
 import (
 	"context"
 	"errors"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
 	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/idempotency"
 	"github.com/JuD4Mo/go_api_web_sdk/course"
 	courseSdk "github.com/JuD4Mo/go_api_web_sdk/course"
 	courseSdkMock "github.com/JuD4Mo/go_api_web_sdk/course/mock"
@@ -17,11 +19,13 @@ import (
 	userSdk "github.com/JuD4Mo/go_api_web_sdk/user"
 	userSdkMock "github.com/JuD4Mo/go_api_web_sdk/user/mock"
 	"github.com/JuD4Mo/go_lib_response/response"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateEndpoint(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.DiscardHandler)
 
 	t.Run("should return bad request when user id is empty", func(t *testing.T) {
 		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
@@ -118,6 +122,9 @@ func TestCreateEndpoint(t *testing.T) {
 				},
 			},
 			repositoryMock: &mockRepository{
+				GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+					return nil, nil
+				},
 				CreateMock: func(ctx context.Context, enrollment *domain.Enrollment) error {
 					return errors.New("unexpected error")
 				},
@@ -125,6 +132,26 @@ func TestCreateEndpoint(t *testing.T) {
 			expectedErr:    errors.New("unexpected error"),
 			expectedStatus: http.StatusInternalServerError,
 		},
+		{
+			tag: "should return a conflict if the user is already enrolled",
+			userSdkMock: &userSdkMock.UserSdkMock{
+				GetMock: func(id string) (*domain.User, error) {
+					return nil, nil
+				},
+			},
+			courseSdkMock: &courseSdkMock.CourseSdkMock{
+				GetMock: func(id string) (*domain.Course, error) {
+					return nil, nil
+				},
+			},
+			repositoryMock: &mockRepository{
+				GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+					return []domain.Enrollment{{ID: "10010", UserID: "1", CourseID: "4"}}, nil
+				},
+			},
+			expectedErr:    enrollment.ErrAlreadyEnrolled{EnrollmentId: "10010", UserId: "1", CourseId: "4"},
+			expectedStatus: http.StatusConflict,
+		},
 		{
 			tag: "should return the enrollment",
 			userSdkMock: &userSdkMock.UserSdkMock{
@@ -138,6 +165,9 @@ func TestCreateEndpoint(t *testing.T) {
 				},
 			},
 			repositoryMock: &mockRepository{
+				GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+					return nil, nil
+				},
 				CreateMock: func(ctx context.Context, enrollment *domain.Enrollment) error {
 					enrollment.ID = "10010"
 					return nil
@@ -154,7 +184,7 @@ func TestCreateEndpoint(t *testing.T) {
 	}
 	for _, obj := range obj {
 		t.Run(obj.tag, func(t *testing.T) {
-			service := enrollment.NewService(l, obj.repositoryMock, obj.userSdkMock, obj.courseSdkMock)
+			service := enrollment.NewService(l, obj.repositoryMock, obj.userSdkMock, obj.courseSdkMock, 0)
 			endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
 			resp, err := endpoint.Create(context.Background(), enrollment.CreateReq{UserId: "1", CourseId: "4"})
 
@@ -165,6 +195,11 @@ func TestCreateEndpoint(t *testing.T) {
 				r := err.(response.Response)
 				assert.EqualError(t, obj.expectedErr, r.Error())
 				assert.Equal(t, obj.expectedStatus, r.StatusCode())
+
+				var errEnrolled enrollment.ErrAlreadyEnrolled
+				if errors.As(obj.expectedErr, &errEnrolled) {
+					assert.Equal(t, map[string]string{"id": "10010"}, r.GetData())
+				}
 			} else {
 				assert.NotNil(t, resp)
 				assert.Nil(t, err)
@@ -184,8 +219,429 @@ func TestCreateEndpoint(t *testing.T) {
 	}
 }
 
+func TestDeleteEndpoint(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("should return an error if the enrollment does not exist", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return nil, enrollment.ErrNotFound{EnrollmentId: id}
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		_, err := endpoint.Delete(context.Background(), enrollment.DeleteReq{ID: "20"})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrNotFound{EnrollmentId: "20"}, resp.Error())
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	})
+
+	t.Run("should return a conflict if the enrollment can not be withdrawn", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: enrollment.Rejected}, nil
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		_, err := endpoint.Delete(context.Background(), enrollment.DeleteReq{ID: "20"})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrInvalidTransition{From: "R", To: "W"}, resp.Error())
+		assert.Equal(t, http.StatusConflict, resp.StatusCode())
+	})
+
+	t.Run("should return an error if repository returns a unexpected error", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: domain.Pending}, nil
+			},
+			DeleteMock: func(ctx context.Context, id string, currentStatus string) error {
+				return errors.New("unexpected error")
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		_, err := endpoint.Delete(context.Background(), enrollment.DeleteReq{ID: "20"})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.Equal(t, "unexpected error", resp.Error())
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
+	})
+
+	t.Run("should return success", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: domain.Active}, nil
+			},
+			DeleteMock: func(ctx context.Context, id string, currentStatus string) error {
+				assert.Equal(t, "20", id)
+				assert.Equal(t, "A", currentStatus)
+				return nil
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		resp, err := endpoint.Delete(context.Background(), enrollment.DeleteReq{ID: "20"})
+		assert.Nil(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusOK, r.StatusCode())
+		assert.Nil(t, r.GetData())
+	})
+}
+
+func TestCreateEndpointWaitlist(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("should return accepted with the waitlist position if the course is full", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
+			CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+				return 30, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				enroll.ID = "10010"
+				return nil
+			},
+			WaitlistPositionMock: func(ctx context.Context, id string) (int, error) {
+				assert.Equal(t, "10010", id)
+				return 2, nil
+			},
+		}, &userSdkMock.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}, &courseSdkMock.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}, 30)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		resp, err := endpoint.Create(context.Background(), enrollment.CreateReq{UserId: "1", CourseId: "4"})
+		assert.Nil(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusAccepted, r.StatusCode())
+
+		entry := r.GetData().(enrollment.WaitlistEntry)
+		assert.Equal(t, 2, entry.Position)
+		assert.Equal(t, "10010", entry.ID)
+		assert.Equal(t, enrollment.Waitlisted, entry.Status)
+	})
+
+	t.Run("should not create the enrollment if its position can not be read", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
+			CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+				return 30, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				enroll.ID = "10010"
+				return nil
+			},
+			WaitlistPositionMock: func(ctx context.Context, id string) (int, error) {
+				return 0, enrollment.ErrNotFound{EnrollmentId: id}
+			},
+		}, &userSdkMock.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}, &courseSdkMock.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}, 30)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		_, err := endpoint.Create(context.Background(), enrollment.CreateReq{UserId: "1", CourseId: "4"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, err.(response.Response).StatusCode())
+	})
+}
+
+func TestWaitlistEndpoint(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("should return bad request when course id is empty", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
+		_, err := endpoint.Waitlist(context.Background(), enrollment.WaitlistReq{})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrCourseIdRequired, resp.Error())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("should return an error if repository returns an unexpected error", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetWaitlistMock: func(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+				return nil, errors.New("unexpected error")
+			},
+		}, nil, nil, 30)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		_, err := endpoint.Waitlist(context.Background(), enrollment.WaitlistReq{CourseID: "4"})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.Equal(t, "unexpected error", resp.Error())
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
+	})
+
+	t.Run("should return the waitlist", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetWaitlistMock: func(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+				assert.Equal(t, "4", courseId)
+				return []domain.Enrollment{{ID: "1"}, {ID: "2"}}, nil
+			},
+		}, nil, nil, 30)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		resp, err := endpoint.Waitlist(context.Background(), enrollment.WaitlistReq{CourseID: "4"})
+		assert.Nil(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusOK, r.StatusCode())
+
+		entries := r.GetData().([]enrollment.WaitlistEntry)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, 1, entries[0].Position)
+		assert.Equal(t, "2", entries[1].ID)
+	})
+}
+
+func TestCreateEndpointIdempotency(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	newService := func(createErr error) enrollment.Service {
+		return enrollment.NewService(l, &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				enroll.ID = "10010"
+				return createErr
+			},
+		}, &userSdkMock.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}, &courseSdkMock.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}, 0)
+	}
+	req := enrollment.CreateReq{UserId: "1", CourseId: "4", IdempotencyKey: "key-1"}
+
+	t.Run("should store the response of the first request", func(t *testing.T) {
+		var stored []byte
+		store := &mockIdempotency{
+			ReserveMock: func(ctx context.Context, subject, key, requestHash string) (*idempotency.Record, error) {
+				assert.Equal(t, "key-1", key)
+				assert.NotEmpty(t, requestHash)
+				return nil, nil
+			},
+			CompleteMock: func(ctx context.Context, subject, key string, statusCode int, body []byte) error {
+				assert.Equal(t, http.StatusCreated, statusCode)
+				stored = body
+				return nil
+			},
+		}
+		endpoint := enrollment.MakeEndpoints(newService(nil), enrollment.Config{Idempotency: store})
+		resp, err := endpoint.Create(context.Background(), req)
+		assert.Nil(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusCreated, r.StatusCode())
+		assert.Contains(t, string(stored), `"id":"10010"`)
+	})
+
+	t.Run("should replay the stored response", func(t *testing.T) {
+		var hash string
+		store := &mockIdempotency{
+			ReserveMock: func(ctx context.Context, subject, key, requestHash string) (*idempotency.Record, error) {
+				hash = requestHash
+				return nil, nil
+			},
+			CompleteMock: func(ctx context.Context, subject, key string, statusCode int, body []byte) error {
+				return nil
+			},
+		}
+		endpoint := enrollment.MakeEndpoints(newService(nil), enrollment.Config{Idempotency: store})
+		_, err := endpoint.Create(context.Background(), req)
+		assert.Nil(t, err)
+
+		store.ReserveMock = func(ctx context.Context, subject, key, requestHash string) (*idempotency.Record, error) {
+			return &idempotency.Record{Key: key, RequestHash: hash, StatusCode: http.StatusCreated, Body: []byte(`{"status":201}`)}, nil
+		}
+		endpoint = enrollment.MakeEndpoints(nil, enrollment.Config{Idempotency: store})
+		resp, err := endpoint.Create(context.Background(), req)
+		assert.Nil(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusCreated, r.StatusCode())
+		body, _ := r.GetBody()
+		assert.Equal(t, `{"status":201}`, string(body))
+	})
+
+	t.Run("should scope the key to the authenticated subject", func(t *testing.T) {
+		var subjects []string
+		store := &mockIdempotency{
+			ReserveMock: func(ctx context.Context, subject, key, requestHash string) (*idempotency.Record, error) {
+				subjects = append(subjects, subject)
+				return nil, nil
+			},
+			CompleteMock: func(ctx context.Context, subject, key string, statusCode int, body []byte) error {
+				subjects = append(subjects, subject)
+				return nil
+			},
+		}
+		ctx := auth.NewContext(context.Background(), &auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "u1"},
+			Roles:            []string{auth.RoleAdmin},
+		})
+
+		endpoint := enrollment.MakeEndpoints(newService(nil), enrollment.Config{Idempotency: store})
+		_, err := endpoint.Create(ctx, req)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"u1", "u1"}, subjects)
+	})
+
+	t.Run("should return unprocessable entity if the key is reused with another body", func(t *testing.T) {
+		store := &mockIdempotency{
+			ReserveMock: func(ctx context.Context, subject, key, requestHash string) (*idempotency.Record, error) {
+				return &idempotency.Record{Key: key, RequestHash: "other", StatusCode: http.StatusCreated}, nil
+			},
+		}
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{Idempotency: store})
+		_, err := endpoint.Create(context.Background(), req)
+		assert.Error(t, err)
+
+		r := err.(response.Response)
+		assert.EqualError(t, idempotency.ErrKeyReused, r.Error())
+		assert.Equal(t, http.StatusUnprocessableEntity, r.StatusCode())
+	})
+
+	t.Run("should release the key when the request fails with a server error", func(t *testing.T) {
+		released := 0
+		store := &mockIdempotency{
+			ReserveMock: func(ctx context.Context, subject, key, requestHash string) (*idempotency.Record, error) {
+				return nil, nil
+			},
+			ReleaseMock: func(ctx context.Context, subject, key string) error {
+				released++
+				return nil
+			},
+		}
+		endpoint := enrollment.MakeEndpoints(newService(errors.New("unexpected error")), enrollment.Config{Idempotency: store})
+		_, err := endpoint.Create(context.Background(), req)
+		assert.Error(t, err)
+
+		r := err.(response.Response)
+		assert.Equal(t, http.StatusInternalServerError, r.StatusCode())
+		assert.Equal(t, 1, released)
+	})
+}
+
+func TestCreateBulkEndpoint(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	userTransport := &userSdkMock.UserSdkMock{
+		GetMock: func(id string) (*domain.User, error) {
+			return nil, nil
+		},
+	}
+	courseTransport := &courseSdkMock.CourseSdkMock{
+		GetMock: func(id string) (*domain.Course, error) {
+			if id == "404" {
+				return nil, courseSdk.ErrNotFound{Message: "course not found"}
+			}
+			return nil, nil
+		},
+	}
+	repo := &mockRepository{
+		GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+			return nil, nil
+		},
+		CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+			return nil
+		},
+	}
+
+	t.Run("should return bad request when there are no items", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
+		_, err := endpoint.CreateBulk(context.Background(), enrollment.CreateBulkReq{})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrItemsRequired, resp.Error())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("should return bad request when there are too many items", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
+		_, err := endpoint.CreateBulk(context.Background(), enrollment.CreateBulkReq{
+			Items: make([]enrollment.CreateReq, enrollment.DefaultMaxBulkItems+1),
+		})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrTooManyItems{Items: enrollment.DefaultMaxBulkItems + 1, Max: enrollment.DefaultMaxBulkItems}, resp.Error())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("should return bad request when there are more items than configured", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{MaxBulkItems: 2})
+		_, err := endpoint.CreateBulk(context.Background(), enrollment.CreateBulkReq{
+			Items: make([]enrollment.CreateReq, 3),
+		})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrTooManyItems{Items: 3, Max: 2}, resp.Error())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("should return the results of every item", func(t *testing.T) {
+		service := enrollment.NewService(l, repo, userTransport, courseTransport, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		resp, err := endpoint.CreateBulk(context.Background(), enrollment.CreateBulkReq{
+			Items: []enrollment.CreateReq{{UserId: "1", CourseId: "4"}, {UserId: "2", CourseId: "404"}},
+		})
+		assert.Nil(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusOK, r.StatusCode())
+
+		results := r.GetData().([]enrollment.BulkResult)
+		assert.Equal(t, enrollment.BulkCreated, results[0].Result)
+		assert.Equal(t, enrollment.BulkNotFound, results[1].Result)
+	})
+
+	t.Run("should return a conflict when an atomic batch is rolled back", func(t *testing.T) {
+		service := enrollment.NewService(l, repo, userTransport, courseTransport, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		_, err := endpoint.CreateBulk(context.Background(), enrollment.CreateBulkReq{
+			Items:  []enrollment.CreateReq{{UserId: "1", CourseId: "4"}, {UserId: "2", CourseId: "404"}},
+			Atomic: true,
+		})
+		assert.Error(t, err)
+
+		r := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrBulkRolledBack, r.Error())
+		assert.Equal(t, http.StatusConflict, r.StatusCode())
+
+		results := r.GetData().([]enrollment.BulkResult)
+		assert.Equal(t, enrollment.BulkRolledBack, results[0].Result)
+		assert.Equal(t, enrollment.BulkNotFound, results[1].Result)
+	})
+}
+
 func TestGetAllEndpoint(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.DiscardHandler)
 
 	t.Run("should return an error if Count returns an unexpected error", func(t *testing.T) {
 		wantErr := errors.New("unexpected error")
@@ -193,7 +649,7 @@ func TestGetAllEndpoint(t *testing.T) {
 			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
 				return 0, errors.New("unexpected error")
 			},
-		}, nil, nil)
+		}, nil, nil, 0)
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
 		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{})
 		assert.Error(t, err)
@@ -209,7 +665,7 @@ func TestGetAllEndpoint(t *testing.T) {
 			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
 				return 3, nil
 			},
-		}, nil, nil)
+		}, nil, nil, 0)
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimitPage: "invalid number"})
 		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{})
 		assert.Error(t, err)
@@ -228,7 +684,7 @@ func TestGetAllEndpoint(t *testing.T) {
 			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
 				return nil, errors.New("unexpected error")
 			},
-		}, nil, nil)
+		}, nil, nil, 0)
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimitPage: "10"})
 		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{})
 		assert.Error(t, err)
@@ -238,6 +694,148 @@ func TestGetAllEndpoint(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
 	})
 
+	t.Run("should return bad request if a status is invalid", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Statuses: []string{"P", "X"}})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrInvalidStatus{Status: "X"}, resp.Error())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("should return bad request if the date range is inverted", func(t *testing.T) {
+		from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{CreatedFrom: &from, CreatedTo: &to})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrInvalidDateRange, resp.Error())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("should return bad request if a sort field is not allowed", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Sort: "-created_at,password"})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrInvalidSort{Field: "password"}, resp.Error())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("should pass the sort fields to GetAll", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+				return 0, nil
+			},
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				assert.Equal(t, []enrollment.SortField{
+					{Column: "created_at", Desc: true},
+					{Column: "status"},
+				}, filters.Sort)
+				return nil, nil
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimitPage: "10"})
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Sort: "-created_at,status"})
+		assert.Nil(t, err)
+	})
+
+	t.Run("should return bad request if the cursor is invalid", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
+		cursor := "not a cursor"
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Cursor: &cursor})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrInvalidCursor, resp.Error())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("should page by cursor without counting", func(t *testing.T) {
+		created := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+		rows := []domain.Enrollment{
+			{ID: "3", CreatedAt: &created},
+			{ID: "2", CreatedAt: &created},
+			{ID: "1", CreatedAt: &created},
+		}
+		service := enrollment.NewService(l, &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				assert.Equal(t, 3, limit)
+				if filters.After == nil {
+					return rows, nil
+				}
+				assert.Equal(t, "2", filters.After.ID)
+				assert.True(t, created.Equal(filters.After.CreatedAt))
+				return rows[2:], nil
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimitPage: "2"})
+
+		cursor := ""
+		resp, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Cursor: &cursor})
+		assert.Nil(t, err)
+
+		page := resp.(response.Response).GetData().(enrollment.CursorPage)
+		assert.Len(t, page.Enrollments, 2)
+		assert.NotEmpty(t, page.NextCursor)
+
+		resp, err = endpoint.GetAll(context.Background(), enrollment.GetAllReq{Cursor: &page.NextCursor})
+		assert.Nil(t, err)
+
+		page = resp.(response.Response).GetData().(enrollment.CursorPage)
+		assert.Len(t, page.Enrollments, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("should cap the limit of a cursor page", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				assert.Equal(t, enrollment.MaxCursorLimit+1, limit)
+				return nil, nil
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimitPage: "10"})
+
+		cursor := ""
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Cursor: &cursor, Limit: 100000000})
+		assert.Nil(t, err)
+	})
+
+	t.Run("should apply the same filters to Count and GetAll", func(t *testing.T) {
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		wantFilters := enrollment.Filters{
+			UserIds:     []string{"11", "22"},
+			CourseIds:   []string{"111"},
+			Statuses:    []string{"P", "A"},
+			CreatedFrom: &from,
+			CreatedTo:   &to,
+		}
+		service := enrollment.NewService(l, &mockRepository{
+			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+				assert.Equal(t, wantFilters, filters)
+				return 0, nil
+			},
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				assert.Equal(t, wantFilters, filters)
+				return nil, nil
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimitPage: "10"})
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{
+			UserIDs:     []string{"11", "22"},
+			CourseIDs:   []string{"111"},
+			Statuses:    []string{"P", "A"},
+			CreatedFrom: &from,
+			CreatedTo:   &to,
+		})
+		assert.Nil(t, err)
+	})
+
 	t.Run("should return the enrollments", func(t *testing.T) {
 		wantEnrollments := []domain.Enrollment{
 			{ID: "1", UserID: "11", CourseID: "111", Status: "P"},
@@ -255,7 +853,7 @@ func TestGetAllEndpoint(t *testing.T) {
 					{ID: "3", UserID: "33", CourseID: "333", Status: "P"},
 				}, nil
 			},
-		}, nil, nil)
+		}, nil, nil, 0)
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimitPage: "10"})
 		resp, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{})
 		assert.Nil(t, err)
@@ -270,8 +868,60 @@ func TestGetAllEndpoint(t *testing.T) {
 	})
 }
 
+func TestGetEndpoint(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("should return an error if repository returns a not found error", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return nil, enrollment.ErrNotFound{EnrollmentId: id}
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		_, err := endpoint.Get(context.Background(), enrollment.GetReq{ID: "20"})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrNotFound{EnrollmentId: "20"}, resp.Error())
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	})
+
+	t.Run("should return an error if repository returns a unexpected error", func(t *testing.T) {
+		wantErr := errors.New("unexpected error")
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return nil, errors.New("unexpected error")
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		_, err := endpoint.Get(context.Background(), enrollment.GetReq{ID: "20"})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, wantErr, resp.Error())
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
+	})
+
+	t.Run("should return the enrollment", func(t *testing.T) {
+		wantEnrollment := &domain.Enrollment{ID: "20", UserID: "11", CourseID: "111", Status: "P"}
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, UserID: "11", CourseID: "111", Status: "P"}, nil
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		resp, err := endpoint.Get(context.Background(), enrollment.GetReq{ID: "20"})
+		assert.Nil(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusOK, r.StatusCode())
+		assert.Empty(t, r.Error())
+		assert.Equal(t, wantEnrollment, r.GetData().(*domain.Enrollment))
+	})
+}
+
 func TestUpdateEndpoint(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.DiscardHandler)
 
 	t.Run("should return an error if status is empty", func(t *testing.T) {
 		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
@@ -286,10 +936,10 @@ func TestUpdateEndpoint(t *testing.T) {
 
 	t.Run("should return an error if repository returns a not found error", func(t *testing.T) {
 		service := enrollment.NewService(l, &mockRepository{
-			UpdateMock: func(ctx context.Context, id string, status *string) error {
-				return enrollment.ErrNotFound{EnrollmentId: id}
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return nil, enrollment.ErrNotFound{EnrollmentId: id}
 			},
-		}, nil, nil)
+		}, nil, nil, 0)
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
 		status := "A"
 		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
@@ -303,10 +953,13 @@ func TestUpdateEndpoint(t *testing.T) {
 	t.Run("should return an error if repository returns a unexpected error", func(t *testing.T) {
 		wantErr := errors.New("unexpected error")
 		service := enrollment.NewService(l, &mockRepository{
-			UpdateMock: func(ctx context.Context, id string, status *string) error {
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: domain.Pending}, nil
+			},
+			UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
 				return errors.New("unexpected error")
 			},
-		}, nil, nil)
+		}, nil, nil, 0)
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
 		status := "A"
 		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
@@ -317,15 +970,53 @@ func TestUpdateEndpoint(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
 	})
 
+	t.Run("should return a conflict if the transition is not allowed", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: domain.Studying}, nil
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		status := "P"
+		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrInvalidTransition{From: "S", To: "P"}, resp.Error())
+		assert.Equal(t, http.StatusConflict, resp.StatusCode())
+	})
+
+	t.Run("should return a conflict if the status changed concurrently", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: domain.Pending}, nil
+			},
+			UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
+				return enrollment.ErrStatusConflict{EnrollmentId: id, Status: *currentStatus}
+			},
+		}, nil, nil, 0)
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
+		status := "A"
+		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
+		assert.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.EqualError(t, enrollment.ErrStatusConflict{EnrollmentId: "20", Status: "P"}, resp.Error())
+		assert.Equal(t, http.StatusConflict, resp.StatusCode())
+	})
+
 	t.Run("should return success", func(t *testing.T) {
 		service := enrollment.NewService(l, &mockRepository{
-			UpdateMock: func(ctx context.Context, id string, status *string) error {
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: domain.Pending}, nil
+			},
+			UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
 				assert.Equal(t, "20", id)
 				assert.NotNil(t, status)
 				assert.Equal(t, "A", *status)
 				return nil
 			},
-		}, nil, nil)
+		}, nil, nil, 0)
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
 		status := "A"
 		resp, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
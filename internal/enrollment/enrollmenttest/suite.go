@@ -0,0 +1,484 @@
+// Package enrollmenttest provides a conformance suite for implementations of
+// enrollment.Repository, so every backend behaves like the GORM repository.
+package enrollmenttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new and empty repository for every call.
+type Factory func(t *testing.T) enrollment.Repository
+
+var base = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// seed stores four enrollments, created an hour apart from e1 (oldest) to e4.
+//
+//	e1: user u1, course c1, P
+//	e2: user u2, course c1, A
+//	e3: user u1, course c2, S
+//	e4: user u3, course c2, P
+func seed(t *testing.T, repo enrollment.Repository) {
+	rows := []struct {
+		id, user, course string
+		status           domain.EnrollStatus
+	}{
+		{"e1", "u1", "c1", domain.Pending},
+		{"e2", "u2", "c1", domain.Active},
+		{"e3", "u1", "c2", domain.Studying},
+		{"e4", "u3", "c2", domain.Pending},
+	}
+
+	for i, r := range rows {
+		created := base.Add(time.Duration(i) * time.Hour)
+		err := repo.Create(context.Background(), &domain.Enrollment{
+			ID:        r.id,
+			UserID:    r.user,
+			CourseID:  r.course,
+			Status:    r.status,
+			CreatedAt: &created,
+			UpdatedAt: &created,
+		})
+		require.NoError(t, err)
+	}
+}
+
+func ids(e []domain.Enrollment) []string {
+	list := make([]string, len(e))
+	for i := range e {
+		list[i] = e[i].ID
+	}
+	return list
+}
+
+// Run executes the conformance suite against the repositories built by newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := newRepo(t)
+
+		enroll := &domain.Enrollment{UserID: "u1", CourseID: "c1", Status: domain.Pending}
+		require.NoError(t, repo.Create(ctx, enroll))
+		assert.NotEmpty(t, enroll.ID)
+		assert.NotNil(t, enroll.CreatedAt)
+
+		got, err := repo.Get(ctx, enroll.ID)
+		require.NoError(t, err)
+		assert.Equal(t, enroll.ID, got.ID)
+		assert.Equal(t, "u1", got.UserID)
+		assert.Equal(t, "c1", got.CourseID)
+		assert.Equal(t, domain.Pending, got.Status)
+	})
+
+	t.Run("get returns ErrNotFound for unknown ids", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.Get(ctx, "unknown")
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "unknown"}, err)
+	})
+
+	t.Run("create rejects a second enrollment of the user in the course", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		err := repo.Create(ctx, &domain.Enrollment{UserID: "u1", CourseID: "c1", Status: domain.Pending})
+		assert.Equal(t, enrollment.ErrAlreadyEnrolled{EnrollmentId: "e1", UserId: "u1", CourseId: "c1"}, err)
+	})
+
+	t.Run("get all orders by created_at desc", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		e, err := repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e4", "e3", "e2", "e1"}, ids(e))
+	})
+
+	t.Run("get all breaks created_at ties by id", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, id := range []string{"b", "c", "a"} {
+			created := base
+			require.NoError(t, repo.Create(ctx, &domain.Enrollment{ID: id, UserID: id, CourseID: "c1", Status: domain.Pending, CreatedAt: &created}))
+		}
+
+		e, err := repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c", "b", "a"}, ids(e))
+	})
+
+	t.Run("get all sorts by the requested columns", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		e, err := repo.GetAll(ctx, enrollment.Filters{Sort: []enrollment.SortField{{Column: "status"}, {Column: "created_at", Desc: true}}}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e2", "e4", "e1", "e3"}, ids(e))
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		from, to := base.Add(time.Hour), base.Add(2*time.Hour)
+		tests := []struct {
+			name    string
+			filters enrollment.Filters
+			want    []string
+		}{
+			{"no filters", enrollment.Filters{}, []string{"e4", "e3", "e2", "e1"}},
+			{"user", enrollment.Filters{UserId: "u1"}, []string{"e3", "e1"}},
+			{"course", enrollment.Filters{CourseId: "c1"}, []string{"e2", "e1"}},
+			{"user and course", enrollment.Filters{UserId: "u1", CourseId: "c2"}, []string{"e3"}},
+			{"user list", enrollment.Filters{UserIds: []string{"u2", "u3"}}, []string{"e4", "e2"}},
+			{"course list", enrollment.Filters{CourseIds: []string{"c2", "c9"}}, []string{"e4", "e3"}},
+			{"statuses", enrollment.Filters{Statuses: []string{"P", "S"}}, []string{"e4", "e3", "e1"}},
+			{"created range", enrollment.Filters{CreatedFrom: &from, CreatedTo: &to}, []string{"e3", "e2"}},
+			{"created from", enrollment.Filters{CreatedFrom: &to}, []string{"e4", "e3"}},
+			{"combined", enrollment.Filters{UserIds: []string{"u1", "u3"}, Statuses: []string{"P"}, CourseId: "c2"}, []string{"e4"}},
+			{"no match", enrollment.Filters{UserId: "u1", Statuses: []string{"A"}}, []string{}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				e, err := repo.GetAll(ctx, tt.filters, 0, 10)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, ids(e))
+
+				count, err := repo.Count(ctx, tt.filters)
+				require.NoError(t, err)
+				assert.Equal(t, len(tt.want), count)
+			})
+		}
+	})
+
+	t.Run("limit and offset", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		tests := []struct {
+			name          string
+			offset, limit int
+			want          []string
+		}{
+			{"first page", 0, 2, []string{"e4", "e3"}},
+			{"second page", 2, 2, []string{"e2", "e1"}},
+			{"last partial page", 3, 2, []string{"e1"}},
+			{"offset past the end", 4, 2, []string{}},
+			{"limit bigger than the total", 0, 100, []string{"e4", "e3", "e2", "e1"}},
+			{"zero limit", 0, 0, []string{}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				e, err := repo.GetAll(ctx, enrollment.Filters{}, tt.offset, tt.limit)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, ids(e))
+			})
+		}
+	})
+
+	t.Run("get all after a cursor", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		e, err := repo.GetAll(ctx, enrollment.Filters{After: &enrollment.Cursor{CreatedAt: base.Add(2 * time.Hour), ID: "e3"}}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e2", "e1"}, ids(e))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		active, pending := "A", "P"
+		require.NoError(t, repo.Update(ctx, "e1", &active, &pending))
+
+		got, err := repo.Get(ctx, "e1")
+		require.NoError(t, err)
+		assert.Equal(t, domain.Active, got.Status)
+	})
+
+	t.Run("update to the current status", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		// mysql only counts the changed rows unless the DSN sets clientFoundRows
+		pending := "P"
+		require.NoError(t, repo.Update(ctx, "e1", &pending, &pending))
+		require.NoError(t, repo.Update(ctx, "e1", &pending, &pending))
+	})
+
+	t.Run("update returns ErrNotFound when no row is affected", func(t *testing.T) {
+		repo := newRepo(t)
+
+		active := "A"
+		err := repo.Update(ctx, "unknown", &active, nil)
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "unknown"}, err)
+	})
+
+	t.Run("update returns ErrStatusConflict when the status changed", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		active, studying := "A", "S"
+		err := repo.Update(ctx, "e1", &active, &studying)
+		assert.Equal(t, enrollment.ErrStatusConflict{EnrollmentId: "e1", Status: "S"}, err)
+	})
+
+	t.Run("delete hides the enrollment unless deleted ones are included", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		require.NoError(t, repo.Delete(ctx, "e2", "A"))
+
+		_, err := repo.Get(ctx, "e2")
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "e2"}, err)
+
+		e, err := repo.GetAll(ctx, enrollment.Filters{CourseId: "c1"}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e1"}, ids(e))
+
+		count, err := repo.Count(ctx, enrollment.Filters{CourseId: "c1"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		e, err = repo.GetAll(ctx, enrollment.Filters{CourseId: "c1", IncludeDeleted: true}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e2", "e1"}, ids(e))
+		assert.Equal(t, enrollment.Withdrawn, e[0].Status)
+
+		err = repo.Delete(ctx, "e2", "A")
+		assert.Equal(t, enrollment.ErrStatusConflict{EnrollmentId: "e2", Status: "A"}, err)
+	})
+
+	t.Run("count by status includes the withdrawn enrollments", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+		require.NoError(t, repo.Delete(ctx, "e2", "A"))
+
+		counts, err := repo.CountByStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[domain.EnrollStatus]int{
+			domain.Pending:       2,
+			domain.Studying:      1,
+			enrollment.Withdrawn: 1,
+		}, counts)
+	})
+
+	t.Run("reactivate restores a withdrawn enrollment", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+		require.NoError(t, repo.Delete(ctx, "e2", "A"))
+
+		enroll := &domain.Enrollment{ID: "e2", Status: enrollment.Waitlisted}
+		require.NoError(t, repo.Reactivate(ctx, enroll))
+		require.NotNil(t, enroll.CreatedAt)
+		assert.True(t, enroll.CreatedAt.After(base))
+
+		got, err := repo.Get(ctx, "e2")
+		require.NoError(t, err)
+		assert.Equal(t, enrollment.Waitlisted, got.Status)
+		assert.Equal(t, "u2", got.UserID)
+
+		// it is now the newest enrollment of the course
+		e, err := repo.GetAll(ctx, enrollment.Filters{CourseId: "c1"}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e2", "e1"}, ids(e))
+
+		err = repo.Reactivate(ctx, &domain.Enrollment{ID: "e1", Status: domain.Pending})
+		assert.Equal(t, enrollment.ErrStatusConflict{EnrollmentId: "e1", Status: "W"}, err)
+	})
+
+	t.Run("waitlist in queue order", func(t *testing.T) {
+		repo := newRepo(t)
+		for i, id := range []string{"w1", "w2", "w3"} {
+			created := base.Add(time.Duration(i) * time.Minute)
+			require.NoError(t, repo.Create(ctx, &domain.Enrollment{ID: id, UserID: id, CourseID: "c1", Status: enrollment.Waitlisted, CreatedAt: &created}))
+		}
+		seed(t, repo)
+
+		e, err := repo.GetWaitlist(ctx, "c1", 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"w1", "w2", "w3"}, ids(e))
+
+		e, err = repo.GetWaitlist(ctx, "c1", 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"w1"}, ids(e))
+	})
+
+	t.Run("waitlist position", func(t *testing.T) {
+		repo := newRepo(t)
+		created := []time.Time{base, base.Add(time.Minute), base.Add(time.Minute)}
+		for i, id := range []string{"w1", "w2", "w3"} {
+			require.NoError(t, repo.Create(ctx, &domain.Enrollment{ID: id, UserID: id, CourseID: "c1", Status: enrollment.Waitlisted, CreatedAt: &created[i]}))
+		}
+		require.NoError(t, repo.Create(ctx, &domain.Enrollment{ID: "w0", UserID: "w0", CourseID: "c2", Status: enrollment.Waitlisted, CreatedAt: &base}))
+		seed(t, repo)
+
+		for id, expected := range map[string]int{"w1": 1, "w2": 2, "w3": 3, "w0": 1} {
+			position, err := repo.WaitlistPosition(ctx, id)
+			require.NoError(t, err, id)
+			assert.Equal(t, expected, position, id)
+		}
+
+		require.NoError(t, repo.Delete(ctx, "w1", string(enrollment.Waitlisted)))
+		position, err := repo.WaitlistPosition(ctx, "w3")
+		require.NoError(t, err)
+		assert.Equal(t, 2, position)
+
+		_, err = repo.WaitlistPosition(ctx, "w1")
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "w1"}, err)
+
+		_, err = repo.WaitlistPosition(ctx, "e1")
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "e1"}, err)
+	})
+
+	t.Run("course capacity", func(t *testing.T) {
+		repo := newRepo(t)
+
+		seats, err := repo.GetCapacity(ctx, "c1")
+		require.NoError(t, err)
+		assert.Nil(t, seats)
+
+		require.NoError(t, repo.SetCapacity(ctx, "c1", 30))
+		require.NoError(t, repo.SetCapacity(ctx, "c2", 10))
+		require.NoError(t, repo.SetCapacity(ctx, "c1", 25))
+
+		seats, err = repo.GetCapacity(ctx, "c1")
+		require.NoError(t, err)
+		require.NotNil(t, seats)
+		assert.Equal(t, 25, *seats)
+
+		err = repo.Transaction(ctx, func(repo enrollment.Repository) error {
+			seats, err := repo.GetCapacity(ctx, "c2")
+			require.NoError(t, err)
+			require.NotNil(t, seats)
+			assert.Equal(t, 10, *seats)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("transaction commits", func(t *testing.T) {
+		repo := newRepo(t)
+
+		err := repo.Transaction(ctx, func(repo enrollment.Repository) error {
+			return repo.Create(ctx, &domain.Enrollment{ID: "e1", UserID: "u1", CourseID: "c1", Status: domain.Pending})
+		})
+		require.NoError(t, err)
+
+		_, err = repo.Get(ctx, "e1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("transaction reads", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		err := repo.Transaction(ctx, func(repo enrollment.Repository) error {
+			e, err := repo.GetAll(ctx, enrollment.Filters{CourseId: "c1"}, 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"e2", "e1"}, ids(e))
+
+			count, err := repo.Count(ctx, enrollment.Filters{CourseId: "c1"})
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+
+			_, err = repo.Get(ctx, "e1")
+			require.NoError(t, err)
+
+			_, err = repo.GetWaitlist(ctx, "c1", 1)
+			return err
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("transaction keeps working after a duplicate", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		err := repo.Transaction(ctx, func(repo enrollment.Repository) error {
+			err := repo.Create(ctx, &domain.Enrollment{UserID: "u1", CourseID: "c1", Status: domain.Pending})
+			assert.Equal(t, enrollment.ErrAlreadyEnrolled{EnrollmentId: "e1", UserId: "u1", CourseId: "c1"}, err)
+
+			return repo.Create(ctx, &domain.Enrollment{ID: "e5", UserID: "u2", CourseID: "c2", Status: domain.Pending})
+		})
+		require.NoError(t, err)
+
+		_, err = repo.Get(ctx, "e5")
+		assert.NoError(t, err)
+	})
+
+	// the course has no capacity row, like the courses on the default
+	// COURSE_CAPACITY, and a single seat taken by whichever transaction
+	// locks the course first
+	t.Run("transactions locking the course take its seat once", func(t *testing.T) {
+		repo := newRepo(t)
+		const workers = 8
+
+		var wg sync.WaitGroup
+		errs := make([]error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = repo.Transaction(ctx, func(repo enrollment.Repository) error {
+					if err := repo.LockCourse(ctx, "c9"); err != nil {
+						return err
+					}
+
+					taken, err := repo.Count(ctx, enrollment.Filters{CourseId: "c9", Statuses: []string{"P"}})
+					if err != nil {
+						return err
+					}
+
+					status := domain.Pending
+					if taken >= 1 {
+						status = enrollment.Waitlisted
+					}
+					id := fmt.Sprintf("e%d", i+10)
+					return repo.Create(ctx, &domain.Enrollment{ID: id, UserID: id, CourseID: "c9", Status: status})
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+
+		pending, err := repo.Count(ctx, enrollment.Filters{CourseId: "c9", Statuses: []string{"P"}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, pending)
+
+		waitlisted, err := repo.Count(ctx, enrollment.Filters{CourseId: "c9", Statuses: []string{string(enrollment.Waitlisted)}})
+		require.NoError(t, err)
+		assert.Equal(t, workers-1, waitlisted)
+	})
+
+	t.Run("transaction rolls back on error", func(t *testing.T) {
+		repo := newRepo(t)
+		wantErr := errors.New("some error")
+
+		err := repo.Transaction(ctx, func(repo enrollment.Repository) error {
+			if err := repo.Create(ctx, &domain.Enrollment{ID: "e1", UserID: "u1", CourseID: "c1", Status: domain.Pending}); err != nil {
+				return err
+			}
+			return wantErr
+		})
+		assert.Equal(t, wantErr, err)
+
+		_, err = repo.Get(ctx, "e1")
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "e1"}, err)
+	})
+}
@@ -8,6 +8,16 @@ import (
 var ErrUserIdRequired = errors.New("user id is required")
 var ErrCourseIdRequired = errors.New("course id is required")
 var ErrStatusRequired = errors.New("status is required")
+var ErrInvalidDateRange = errors.New("created_from must be before created_to")
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrCursorSort = errors.New("sort is not supported with cursor pagination")
+var ErrItemsRequired = errors.New("items are required")
+var ErrInvalidSeats = errors.New("seats must be greater than zero")
+var ErrBulkRolledBack = errors.New("bulk enrollment rolled back, no enrollment was created")
+var ErrForbiddenUser = errors.New("students can only act on their own enrollments")
+var ErrForbiddenStatus = errors.New("only admins can change the status of an enrollment")
+var ErrForbiddenDeleted = errors.New("only admins can list the withdrawn enrollments")
+var ErrForbiddenRole = errors.New("the caller has no role allowed to make this request")
 
 type ErrNotFound struct {
 	EnrollmentId string
@@ -17,6 +27,36 @@ type ErrInvalidStatus struct {
 	Status string
 }
 
+type ErrInvalidTransition struct {
+	From string
+	To   string
+}
+
+type ErrStatusConflict struct {
+	EnrollmentId string
+	Status       string
+}
+
+type ErrAlreadyEnrolled struct {
+	EnrollmentId string
+	UserId       string
+	CourseId     string
+}
+
+type ErrCourseFull struct {
+	CourseId string
+	Capacity int
+}
+
+type ErrTooManyItems struct {
+	Items int
+	Max   int
+}
+
+type ErrInvalidSort struct {
+	Field string
+}
+
 func (e ErrNotFound) Error() string {
 	return fmt.Sprintf("enrollment '%s' does not exist", e.EnrollmentId)
 }
@@ -24,3 +64,27 @@ func (e ErrNotFound) Error() string {
 func (e ErrInvalidStatus) Error() string {
 	return fmt.Sprintf("invalid '%s' status", e.Status)
 }
+
+func (e ErrInvalidTransition) Error() string {
+	return fmt.Sprintf("invalid status transition from '%s' to '%s'", e.From, e.To)
+}
+
+func (e ErrStatusConflict) Error() string {
+	return fmt.Sprintf("enrollment '%s' is no longer in '%s' status", e.EnrollmentId, e.Status)
+}
+
+func (e ErrAlreadyEnrolled) Error() string {
+	return fmt.Sprintf("user '%s' is already enrolled in course '%s'", e.UserId, e.CourseId)
+}
+
+func (e ErrCourseFull) Error() string {
+	return fmt.Sprintf("course '%s' has no seats available (capacity %d)", e.CourseId, e.Capacity)
+}
+
+func (e ErrTooManyItems) Error() string {
+	return fmt.Sprintf("the batch has %d items, at most %d items can be enrolled at once, split it in smaller batches", e.Items, e.Max)
+}
+
+func (e ErrInvalidSort) Error() string {
+	return fmt.Sprintf("invalid sort field '%s'", e.Field)
+}
@@ -0,0 +1,101 @@
+package enrollment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/idempotency"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"github.com/JuD4Mo/go_lib_response/response"
+)
+
+// idempotentCreate wraps the create controller so that requests carrying an
+// Idempotency-Key are processed once, retries get the stored response back.
+// The keys belong to the authenticated subject, a caller never gets the
+// response stored for the same key by another one.
+func idempotentCreate(log *slog.Logger, store idempotency.Repository, next Controller) Controller {
+	if store == nil {
+		return next
+	}
+
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateReq)
+		if req.IdempotencyKey == "" {
+			return next(ctx, request)
+		}
+
+		hash, err := requestHash(req)
+		if err != nil {
+			return nil, response.InternalServerError(err.Error())
+		}
+
+		subject := auth.Subject(ctx)
+		record, err := store.Reserve(ctx, subject, req.IdempotencyKey, hash)
+		if err != nil {
+			return nil, response.InternalServerError(err.Error())
+		}
+
+		if record != nil {
+			if record.RequestHash != hash {
+				return nil, unprocessableEntity(idempotency.ErrKeyReused.Error())
+			}
+
+			if record.StatusCode == 0 {
+				return nil, conflict(idempotency.ErrInProgress.Error(), nil)
+			}
+
+			replay := &idempotency.Response{Status: record.StatusCode, Body: record.Body}
+			if record.StatusCode >= http.StatusBadRequest {
+				return nil, replay
+			}
+			return replay, nil
+		}
+
+		resp, err := next(ctx, request)
+
+		r, ok := resp.(response.Response)
+		if err != nil {
+			r, ok = err.(response.Response)
+		}
+
+		// Server errors are not stored so the client can retry them
+		if !ok || r.StatusCode() >= http.StatusInternalServerError {
+			if releaseErr := store.Release(ctx, subject, req.IdempotencyKey); releaseErr != nil {
+				log.ErrorContext(ctx, "releasing idempotency key", "idempotency_key", req.IdempotencyKey, logging.Err(releaseErr))
+			}
+			return resp, err
+		}
+
+		body, bodyErr := r.GetBody()
+		if bodyErr == nil {
+			bodyErr = store.Complete(ctx, subject, req.IdempotencyKey, r.StatusCode(), body)
+		}
+		if bodyErr != nil {
+			log.ErrorContext(ctx, "storing idempotent response", "idempotency_key", req.IdempotencyKey, logging.Err(bodyErr))
+		}
+
+		return resp, err
+	}
+}
+
+func requestHash(req CreateReq) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func unprocessableEntity(msg string) response.Response {
+	return &response.ErrorResponse{
+		Status:  http.StatusUnprocessableEntity,
+		Message: msg,
+	}
+}
@@ -0,0 +1,201 @@
+package enrollment
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	courseSDK "github.com/JuD4Mo/go_api_web_sdk/course"
+	userSDK "github.com/JuD4Mo/go_api_web_sdk/user"
+	"github.com/go-kit/kit/metrics"
+)
+
+type (
+	instrumentingRepo struct {
+		next       Repository
+		operations metrics.Counter
+		latency    metrics.Histogram
+	}
+
+	instrumentingUserTransport struct {
+		next     userSDK.Transport
+		requests metrics.Counter
+		latency  metrics.Histogram
+	}
+
+	instrumentingCourseTransport struct {
+		next     courseSDK.Transport
+		requests metrics.Counter
+		latency  metrics.Histogram
+	}
+)
+
+// NewInstrumentingRepo counts and times the operations of next, labeled by
+// operation and result.
+func NewInstrumentingRepo(next Repository, operations metrics.Counter, latency metrics.Histogram) Repository {
+	return &instrumentingRepo{
+		next:       next,
+		operations: operations,
+		latency:    latency,
+	}
+}
+
+func (r *instrumentingRepo) Create(ctx context.Context, enroll *domain.Enrollment) (err error) {
+	defer func(begin time.Time) { r.observe("create", begin, err) }(time.Now())
+	return r.next.Create(ctx, enroll)
+}
+
+func (r *instrumentingRepo) GetAll(ctx context.Context, filters Filters, offset, limit int) (e []domain.Enrollment, err error) {
+	defer func(begin time.Time) { r.observe("get_all", begin, err) }(time.Now())
+	return r.next.GetAll(ctx, filters, offset, limit)
+}
+
+func (r *instrumentingRepo) Get(ctx context.Context, id string) (enroll *domain.Enrollment, err error) {
+	defer func(begin time.Time) { r.observe("get", begin, err) }(time.Now())
+	return r.next.Get(ctx, id)
+}
+
+func (r *instrumentingRepo) GetWaitlist(ctx context.Context, courseId string, limit int) (e []domain.Enrollment, err error) {
+	defer func(begin time.Time) { r.observe("get_waitlist", begin, err) }(time.Now())
+	return r.next.GetWaitlist(ctx, courseId, limit)
+}
+
+func (r *instrumentingRepo) WaitlistPosition(ctx context.Context, id string) (position int, err error) {
+	defer func(begin time.Time) { r.observe("waitlist_position", begin, err) }(time.Now())
+	return r.next.WaitlistPosition(ctx, id)
+}
+
+func (r *instrumentingRepo) Update(ctx context.Context, id string, status, currentStatus *string) (err error) {
+	defer func(begin time.Time) { r.observe("update", begin, err) }(time.Now())
+	return r.next.Update(ctx, id, status, currentStatus)
+}
+
+func (r *instrumentingRepo) Count(ctx context.Context, filters Filters) (count int, err error) {
+	defer func(begin time.Time) { r.observe("count", begin, err) }(time.Now())
+	return r.next.Count(ctx, filters)
+}
+
+func (r *instrumentingRepo) CountByStatus(ctx context.Context) (counts map[domain.EnrollStatus]int, err error) {
+	defer func(begin time.Time) { r.observe("count_by_status", begin, err) }(time.Now())
+	return r.next.CountByStatus(ctx)
+}
+
+func (r *instrumentingRepo) Delete(ctx context.Context, id string, currentStatus string) (err error) {
+	defer func(begin time.Time) { r.observe("delete", begin, err) }(time.Now())
+	return r.next.Delete(ctx, id, currentStatus)
+}
+
+func (r *instrumentingRepo) Reactivate(ctx context.Context, enroll *domain.Enrollment) (err error) {
+	defer func(begin time.Time) { r.observe("reactivate", begin, err) }(time.Now())
+	return r.next.Reactivate(ctx, enroll)
+}
+
+func (r *instrumentingRepo) GetCapacity(ctx context.Context, courseId string) (seats *int, err error) {
+	defer func(begin time.Time) { r.observe("get_capacity", begin, err) }(time.Now())
+	return r.next.GetCapacity(ctx, courseId)
+}
+
+func (r *instrumentingRepo) SetCapacity(ctx context.Context, courseId string, seats int) (err error) {
+	defer func(begin time.Time) { r.observe("set_capacity", begin, err) }(time.Now())
+	return r.next.SetCapacity(ctx, courseId, seats)
+}
+
+func (r *instrumentingRepo) LockCourse(ctx context.Context, courseId string) (err error) {
+	defer func(begin time.Time) { r.observe("lock_course", begin, err) }(time.Now())
+	return r.next.LockCourse(ctx, courseId)
+}
+
+// Transaction is timed as a whole, the operations made inside it are
+// instrumented too.
+func (r *instrumentingRepo) Transaction(ctx context.Context, fn func(repo Repository) error) (err error) {
+	defer func(begin time.Time) { r.observe("transaction", begin, err) }(time.Now())
+	return r.next.Transaction(ctx, func(repo Repository) error {
+		return fn(NewInstrumentingRepo(repo, r.operations, r.latency))
+	})
+}
+
+func (r *instrumentingRepo) observe(operation string, begin time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+
+	r.operations.With("operation", operation, "result", result).Add(1)
+	r.latency.With("operation", operation, "result", result).Observe(time.Since(begin).Seconds())
+}
+
+// NewInstrumentingUserTransport counts and times the calls to the user
+// service, labeled by dependency, operation and result.
+func NewInstrumentingUserTransport(next userSDK.Transport, requests metrics.Counter, latency metrics.Histogram) userSDK.Transport {
+	return &instrumentingUserTransport{
+		next:     next,
+		requests: requests,
+		latency:  latency,
+	}
+}
+
+func (t *instrumentingUserTransport) Get(id string) (user *domain.User, err error) {
+	defer func(begin time.Time) {
+		result := dependencyResult(err, errors.As(err, &userSDK.ErrNotFound{}))
+		t.requests.With("dependency", "user_service", "operation", "get", "result", result).Add(1)
+		t.latency.With("dependency", "user_service", "operation", "get", "result", result).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return t.next.Get(id)
+}
+
+// NewInstrumentingCourseTransport counts and times the calls to the course
+// service, labeled by dependency, operation and result.
+func NewInstrumentingCourseTransport(next courseSDK.Transport, requests metrics.Counter, latency metrics.Histogram) courseSDK.Transport {
+	return &instrumentingCourseTransport{
+		next:     next,
+		requests: requests,
+		latency:  latency,
+	}
+}
+
+func (t *instrumentingCourseTransport) Get(id string) (course *domain.Course, err error) {
+	defer func(begin time.Time) {
+		result := dependencyResult(err, errors.As(err, &courseSDK.ErrNotFound{}))
+		t.requests.With("dependency", "course_service", "operation", "get", "result", result).Add(1)
+		t.latency.With("dependency", "course_service", "operation", "get", "result", result).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return t.next.Get(id)
+}
+
+func dependencyResult(err error, notFound bool) string {
+	switch {
+	case err == nil:
+		return "success"
+	case notFound:
+		return "not_found"
+	}
+	return "error"
+}
+
+// ReportStatuses sets the gauge with the number of enrollments of every
+// status, withdrawn ones included, each interval until ctx is done. The
+// statuses are counted by a single query.
+func ReportStatuses(ctx context.Context, log *slog.Logger, s Service, gauge metrics.Gauge, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		counts, err := s.CountByStatus(ctx)
+		if err != nil {
+			log.ErrorContext(ctx, "counting enrollments by status", logging.Err(err))
+		} else {
+			for status := range transitions {
+				gauge.With("status", string(status)).Set(float64(counts[status]))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
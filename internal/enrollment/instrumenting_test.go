@@ -0,0 +1,171 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/metrics"
+	userSdk "github.com/JuD4Mo/go_api_web_sdk/user"
+	userSdkMock "github.com/JuD4Mo/go_api_web_sdk/user/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricValue returns the value of the counter or gauge, or the number of
+// observations of the histogram, with exactly the given labels.
+func metricValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+	metrics:
+		for _, m := range family.GetMetric() {
+			if len(m.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+
+			switch {
+			case m.GetCounter() != nil:
+				return m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				return m.GetGauge().GetValue()
+			case m.GetHistogram() != nil:
+				return float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return 0
+}
+
+func TestInstrumentingRepo(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	mock := &mockRepository{
+		GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+			if id == "unknown" {
+				return nil, enrollment.ErrNotFound{EnrollmentId: id}
+			}
+			return &domain.Enrollment{ID: id}, nil
+		},
+		CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+			return 3, nil
+		},
+	}
+	repo := enrollment.NewInstrumentingRepo(mock, m.RepositoryOperations, m.RepositoryLatency)
+
+	_, err := repo.Get(ctx, "1")
+	assert.NoError(t, err)
+	_, err = repo.Get(ctx, "2")
+	assert.NoError(t, err)
+	_, err = repo.Get(ctx, "unknown")
+	assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "unknown"}, err)
+
+	err = repo.Transaction(ctx, func(repo enrollment.Repository) error {
+		_, err := repo.Count(ctx, enrollment.Filters{})
+		return err
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		operation, result string
+		want              float64
+	}{
+		{"get", "success", 2},
+		{"get", "error", 1},
+		{"transaction", "success", 1},
+		{"count", "success", 1},
+	}
+	for _, tt := range tests {
+		labels := map[string]string{"operation": tt.operation, "result": tt.result}
+		assert.Equal(t, tt.want, metricValue(t, reg, "enrollment_repository_operations_total", labels), labels)
+		assert.Equal(t, tt.want, metricValue(t, reg, "enrollment_repository_operation_duration_seconds", labels), labels)
+	}
+}
+
+func TestInstrumentingUserTransport(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	var getErr error
+	transport := enrollment.NewInstrumentingUserTransport(&userSdkMock.UserSdkMock{
+		GetMock: func(id string) (*domain.User, error) {
+			return nil, getErr
+		},
+	}, m.DependencyRequests, m.DependencyLatency)
+
+	_, _ = transport.Get("1")
+	getErr = userSdk.ErrNotFound{Message: "user not found"}
+	_, _ = transport.Get("2")
+	getErr = errors.New("connection refused")
+	_, _ = transport.Get("3")
+
+	for _, result := range []string{"success", "not_found", "error"} {
+		labels := map[string]string{"dependency": "user_service", "operation": "get", "result": result}
+		assert.Equal(t, float64(1), metricValue(t, reg, "enrollment_dependency_requests_total", labels), result)
+		assert.Equal(t, float64(1), metricValue(t, reg, "enrollment_dependency_request_duration_seconds", labels), result)
+	}
+}
+
+func TestReportStatuses(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	calls := 0
+	repo := &mockRepository{
+		CountByStatusMock: func(ctx context.Context) (map[domain.EnrollStatus]int, error) {
+			calls++
+			return map[domain.EnrollStatus]int{domain.Pending: 1, enrollment.Waitlisted: 2}, nil
+		},
+	}
+	svc := enrollment.NewService(slog.New(slog.DiscardHandler), repo, nil, nil, 0)
+
+	// a done context reports once and returns
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	enrollment.ReportStatuses(ctx, slog.New(slog.DiscardHandler), svc, m.Enrollments, time.Minute)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, float64(1), metricValue(t, reg, "enrollment_enrollments", map[string]string{"status": "P"}))
+	assert.Equal(t, float64(2), metricValue(t, reg, "enrollment_enrollments", map[string]string{"status": "WL"}))
+	assert.Equal(t, float64(0), metricValue(t, reg, "enrollment_enrollments", map[string]string{"status": "R"}))
+}
+
+func TestReportStatuses_error(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	repo := &mockRepository{
+		CountByStatusMock: func(ctx context.Context) (map[domain.EnrollStatus]int, error) {
+			return nil, errors.New("some error")
+		},
+	}
+	svc := enrollment.NewService(slog.New(slog.DiscardHandler), repo, nil, nil, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	enrollment.ReportStatuses(ctx, slog.New(slog.DiscardHandler), svc, m.Enrollments, time.Minute)
+
+	// the gauge keeps its last values instead of dropping to zero
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		assert.NotEqual(t, "enrollment_enrollments", family.GetName())
+	}
+}
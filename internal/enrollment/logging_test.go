@@ -0,0 +1,94 @@
+package enrollment_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	courseSdkMock "github.com/JuD4Mo/go_api_web_sdk/course/mock"
+	userSdkMock "github.com/JuD4Mo/go_api_web_sdk/user/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Logging(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := logging.New(&buf, "json", "info")
+	require.NoError(t, err)
+
+	svc := enrollment.NewService(l, enrollment.NewMemoryRepo(l),
+		&userSdkMock.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return &domain.User{ID: id}, nil
+			},
+		},
+		&courseSdkMock.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return &domain.Course{ID: id}, nil
+			},
+		}, 0)
+
+	ctx := logging.With(context.Background(), logging.RequestIDKey, "r1")
+
+	enroll, _, err := svc.Create(ctx, "u1", "c1")
+	require.NoError(t, err)
+
+	_, err = svc.Get(ctx, "missing")
+	require.Error(t, err)
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, "enrollment created", lines[0]["msg"])
+	assert.Equal(t, "r1", lines[0][logging.RequestIDKey])
+	assert.Equal(t, "u1", lines[0][logging.UserIDKey])
+	assert.Equal(t, "c1", lines[0][logging.CourseIDKey])
+	assert.Equal(t, enroll.ID, lines[0][logging.EnrollmentIDKey])
+
+	assert.Equal(t, "enrollment not found", lines[1]["msg"])
+	assert.Equal(t, "r1", lines[1][logging.RequestIDKey])
+	assert.Equal(t, "missing", lines[1][logging.EnrollmentIDKey])
+	assert.NotContains(t, lines[1], logging.UserIDKey)
+}
+
+func TestEndpoints_Logging(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := logging.New(&buf, "json", "info")
+	require.NoError(t, err)
+
+	verifier := &verifierMock{
+		VerifyMock: func(token string) (*auth.Claims, error) {
+			return nil, auth.ErrInvalidToken{Err: errors.New("token is expired")}
+		},
+	}
+	endpoints := enrollment.MakeEndpoints(nil, enrollment.Config{LimitPage: "10", Auth: verifier, Logger: l})
+
+	ctx := logging.With(tokenContext("Bearer expired"), logging.RequestIDKey, "r1")
+	_, err = endpoints.Get(ctx, enrollment.GetReq{ID: "e1"})
+	require.Error(t, err)
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "token rejected", lines[0]["msg"])
+	assert.Equal(t, "invalid token: token is expired", lines[0]["error"])
+	assert.Equal(t, "r1", lines[0][logging.RequestIDKey])
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &fields))
+		lines = append(lines, fields)
+	}
+	return lines
+}
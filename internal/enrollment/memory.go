@@ -0,0 +1,408 @@
+package enrollment
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+)
+
+type (
+	// memoryRepo is a Repository kept in memory, meant for local development
+	// and tests. It mirrors the filtering, ordering, pagination and error
+	// semantics of the GORM repository.
+	memoryRepo struct {
+		// tx serializes transactions, mu guards the data of every operation
+		tx   sync.Mutex
+		mu   sync.RWMutex
+		data map[string]memoryRow
+		// capacities are the seats by course id
+		capacities map[string]int
+		log        *slog.Logger
+	}
+
+	memoryRow struct {
+		enroll    domain.Enrollment
+		deletedAt *time.Time
+	}
+
+	// memoryTx is the repository handed to Transaction, it shares the data of
+	// the memoryRepo that is already locked by the transaction.
+	memoryTx struct {
+		*memoryRepo
+	}
+)
+
+func NewMemoryRepo(log *slog.Logger) Repository {
+	return &memoryRepo{
+		data:       make(map[string]memoryRow),
+		capacities: make(map[string]int),
+		log:        log,
+	}
+}
+
+func (repo *memoryRepo) Create(ctx context.Context, enroll *domain.Enrollment) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, row := range repo.data {
+		if row.enroll.UserID == enroll.UserID && row.enroll.CourseID == enroll.CourseID {
+			return ErrAlreadyEnrolled{EnrollmentId: row.enroll.ID, UserId: enroll.UserID, CourseId: enroll.CourseID}
+		}
+	}
+
+	if enroll.ID == "" {
+		enroll.ID = newID()
+	}
+	now := time.Now()
+	if enroll.CreatedAt == nil {
+		enroll.CreatedAt = &now
+	}
+	if enroll.UpdatedAt == nil {
+		enroll.UpdatedAt = &now
+	}
+
+	repo.data[enroll.ID] = memoryRow{enroll: *enroll}
+	return nil
+}
+
+func (repo *memoryRepo) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	e := repo.filter(filters)
+	if filters.After != nil {
+		after := e[:0]
+		for _, enroll := range e {
+			createdAt := createdAt(enroll)
+			if createdAt.Before(filters.After.CreatedAt) ||
+				(createdAt.Equal(filters.After.CreatedAt) && enroll.ID < filters.After.ID) {
+				after = append(after, enroll)
+			}
+		}
+		e = after
+	}
+
+	sortEnrollments(e, filters.Sort)
+
+	if offset > len(e) {
+		offset = len(e)
+	}
+	e = e[offset:]
+	if limit >= 0 && limit < len(e) {
+		e = e[:limit]
+	}
+	return e, nil
+}
+
+func (repo *memoryRepo) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	row, ok := repo.data[id]
+	if !ok || row.deletedAt != nil {
+		repo.log.InfoContext(ctx, "enrollment not found", logging.EnrollmentIDKey, id)
+		return nil, ErrNotFound{EnrollmentId: id}
+	}
+
+	enroll := row.enroll
+	return &enroll, nil
+}
+
+func (repo *memoryRepo) GetWaitlist(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	e := repo.filter(Filters{CourseId: courseId, Statuses: []string{string(Waitlisted)}})
+	sortEnrollments(e, []SortField{{Column: "created_at"}})
+
+	if limit > 0 && limit < len(e) {
+		e = e[:limit]
+	}
+	return e, nil
+}
+
+func (repo *memoryRepo) WaitlistPosition(ctx context.Context, id string) (int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	row, ok := repo.data[id]
+	if !ok || row.deletedAt != nil || row.enroll.Status != Waitlisted {
+		repo.log.InfoContext(ctx, "waitlisted enrollment not found", logging.EnrollmentIDKey, id)
+		return 0, ErrNotFound{EnrollmentId: id}
+	}
+
+	position := 1
+	created := createdAt(row.enroll)
+	for _, enroll := range repo.filter(Filters{CourseId: row.enroll.CourseID, Statuses: []string{string(Waitlisted)}}) {
+		if c := createdAt(enroll); c.Before(created) || (c.Equal(created) && enroll.ID < id) {
+			position++
+		}
+	}
+	return position, nil
+}
+
+func (repo *memoryRepo) Update(ctx context.Context, id string, status, currentStatus *string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	row, ok := repo.data[id]
+	if ok && currentStatus != nil && string(row.enroll.Status) != *currentStatus {
+		repo.log.InfoContext(ctx, "enrollment is no longer in the expected status", logging.EnrollmentIDKey, id, "status", *currentStatus)
+		return ErrStatusConflict{EnrollmentId: id, Status: *currentStatus}
+	}
+
+	if !ok {
+		if currentStatus != nil {
+			return ErrStatusConflict{EnrollmentId: id, Status: *currentStatus}
+		}
+		repo.log.InfoContext(ctx, "enrollment not found", logging.EnrollmentIDKey, id)
+		return ErrNotFound{EnrollmentId: id}
+	}
+
+	if status != nil {
+		row.enroll.Status = domain.EnrollStatus(*status)
+	}
+	now := time.Now()
+	row.enroll.UpdatedAt = &now
+
+	repo.data[id] = row
+	return nil
+}
+
+func (repo *memoryRepo) Count(ctx context.Context, filters Filters) (int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return len(repo.filter(filters)), nil
+}
+
+func (repo *memoryRepo) CountByStatus(ctx context.Context) (map[domain.EnrollStatus]int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	counts := make(map[domain.EnrollStatus]int)
+	for _, row := range repo.data {
+		counts[row.enroll.Status]++
+	}
+	return counts, nil
+}
+
+func (repo *memoryRepo) Delete(ctx context.Context, id string, currentStatus string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	row, ok := repo.data[id]
+	if !ok || row.deletedAt != nil || string(row.enroll.Status) != currentStatus {
+		repo.log.InfoContext(ctx, "enrollment is no longer in the expected status", logging.EnrollmentIDKey, id, "status", currentStatus)
+		return ErrStatusConflict{EnrollmentId: id, Status: currentStatus}
+	}
+
+	now := time.Now()
+	row.enroll.Status, row.enroll.UpdatedAt, row.deletedAt = Withdrawn, &now, &now
+
+	repo.data[id] = row
+	return nil
+}
+
+func (repo *memoryRepo) Reactivate(ctx context.Context, enroll *domain.Enrollment) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	row, ok := repo.data[enroll.ID]
+	if !ok || row.enroll.Status != Withdrawn {
+		repo.log.InfoContext(ctx, "enrollment is no longer in the expected status", logging.EnrollmentIDKey, enroll.ID, "status", Withdrawn)
+		return ErrStatusConflict{EnrollmentId: enroll.ID, Status: string(Withdrawn)}
+	}
+
+	now := time.Now()
+	row.enroll.Status, row.enroll.CreatedAt, row.enroll.UpdatedAt, row.deletedAt = enroll.Status, &now, &now, nil
+	enroll.CreatedAt, enroll.UpdatedAt = &now, &now
+
+	repo.data[enroll.ID] = row
+	return nil
+}
+
+func (repo *memoryRepo) GetCapacity(ctx context.Context, courseId string) (*int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	seats, ok := repo.capacities[courseId]
+	if !ok {
+		return nil, nil
+	}
+	return &seats, nil
+}
+
+func (repo *memoryRepo) SetCapacity(ctx context.Context, courseId string, seats int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.capacities[courseId] = seats
+	return nil
+}
+
+// Transaction runs fn holding the transaction lock, the data is restored when
+// fn returns an error.
+// LockCourse does nothing, the transactions already run one at a time.
+func (repo *memoryRepo) LockCourse(ctx context.Context, courseId string) error {
+	return nil
+}
+
+func (repo *memoryRepo) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	repo.tx.Lock()
+	defer repo.tx.Unlock()
+
+	repo.mu.RLock()
+	snapshot := maps.Clone(repo.data)
+	capacities := maps.Clone(repo.capacities)
+	repo.mu.RUnlock()
+
+	if err := fn(memoryTx{repo}); err != nil {
+		repo.mu.Lock()
+		repo.data, repo.capacities = snapshot, capacities
+		repo.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Transaction of a repository already in a transaction runs fn in it, as
+// nested transactions would wait forever on the transaction lock.
+func (tx memoryTx) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return fn(tx)
+}
+
+// filter returns copies of the enrollments matching the filters, in no order.
+func (repo *memoryRepo) filter(filters Filters) []domain.Enrollment {
+	e := []domain.Enrollment{}
+	for _, row := range repo.data {
+		if matches(row, filters) {
+			e = append(e, row.enroll)
+		}
+	}
+	return e
+}
+
+func matches(row memoryRow, filters Filters) bool {
+	enroll := row.enroll
+
+	if filters.UserId != "" && enroll.UserID != filters.UserId {
+		return false
+	}
+	if filters.CourseId != "" && enroll.CourseID != filters.CourseId {
+		return false
+	}
+	if len(filters.UserIds) > 0 && !contains(filters.UserIds, enroll.UserID) {
+		return false
+	}
+	if len(filters.CourseIds) > 0 && !contains(filters.CourseIds, enroll.CourseID) {
+		return false
+	}
+	if filters.CreatedFrom != nil && createdAt(enroll).Before(*filters.CreatedFrom) {
+		return false
+	}
+	if filters.CreatedTo != nil && createdAt(enroll).After(*filters.CreatedTo) {
+		return false
+	}
+	if len(filters.Statuses) > 0 && !contains(filters.Statuses, string(enroll.Status)) {
+		return false
+	}
+	if !filters.IncludeDeleted && row.deletedAt != nil {
+		return false
+	}
+	return true
+}
+
+// sortEnrollments orders like applySort does in SQL.
+func sortEnrollments(e []domain.Enrollment, fields []SortField) {
+	if len(fields) == 0 {
+		fields = []SortField{{Column: "created_at", Desc: true}}
+	}
+
+	hasID := false
+	for _, field := range fields {
+		hasID = hasID || field.Column == "id"
+	}
+	if !hasID {
+		fields = append(fields[:len(fields):len(fields)], SortField{Column: "id", Desc: fields[0].Desc})
+	}
+
+	sort.SliceStable(e, func(i, j int) bool {
+		for _, field := range fields {
+			c := compareColumn(e[i], e[j], field.Column)
+			if c == 0 {
+				continue
+			}
+			if field.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+func compareColumn(a, b domain.Enrollment, column string) int {
+	switch column {
+	case "created_at":
+		return createdAt(a).Compare(createdAt(b))
+	case "updated_at":
+		return timeOf(a.UpdatedAt).Compare(timeOf(b.UpdatedAt))
+	case "user_id":
+		return compareStrings(a.UserID, b.UserID)
+	case "course_id":
+		return compareStrings(a.CourseID, b.CourseID)
+	case "status":
+		return compareStrings(string(a.Status), string(b.Status))
+	default:
+		return compareStrings(a.ID, b.ID)
+	}
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func createdAt(enroll domain.Enrollment) time.Time {
+	return timeOf(enroll.CreatedAt)
+}
+
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// newID returns a random (version 4) UUID like the ones the database model
+// assigns to new enrollments.
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
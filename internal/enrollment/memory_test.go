@@ -0,0 +1,74 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+	ctx := context.Background()
+
+	t.Run("should roll back the changes of a failed transaction", func(t *testing.T) {
+		repo := enrollment.NewMemoryRepo(l)
+
+		err := repo.Transaction(ctx, func(repo enrollment.Repository) error {
+			if err := repo.Create(ctx, &domain.Enrollment{UserID: "1", CourseID: "1", Status: domain.Pending}); err != nil {
+				return err
+			}
+			return errors.New("some error")
+		})
+		assert.EqualError(t, err, "some error")
+
+		count, err := repo.Count(ctx, enrollment.Filters{})
+		assert.Nil(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("should run the service without a database", func(t *testing.T) {
+		repo := enrollment.NewMemoryRepo(l)
+		service := enrollment.NewService(l, repo, nil, nil, 0)
+
+		enroll := &domain.Enrollment{UserID: "1", CourseID: "1", Status: domain.Pending}
+		assert.Nil(t, repo.Create(ctx, enroll))
+		assert.NotEmpty(t, enroll.ID)
+
+		status := "A"
+		assert.Nil(t, service.Update(ctx, enroll.ID, &status))
+
+		got, err := service.Get(ctx, enroll.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, domain.Active, got.Status)
+	})
+
+	t.Run("should not give away more seats than the capacity under concurrency", func(t *testing.T) {
+		repo := enrollment.NewMemoryRepo(l)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_ = repo.Transaction(ctx, func(repo enrollment.Repository) error {
+					taken, err := repo.Count(ctx, enrollment.Filters{CourseId: "1"})
+					if err != nil || taken >= 5 {
+						return err
+					}
+					return repo.Create(ctx, &domain.Enrollment{UserID: string(rune('a' + i)), CourseID: "1", Status: domain.Pending})
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		count, err := repo.Count(ctx, enrollment.Filters{CourseId: "1"})
+		assert.Nil(t, err)
+		assert.Equal(t, 5, count)
+	})
+}
@@ -5,13 +5,30 @@ import (
 
 	"github.com/JuD4Mo/go_api_web_domain/domain"
 	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/idempotency"
 )
 
 type mockRepository struct {
-	CreateMock func(ctx context.Context, enroll *domain.Enrollment) error
-	GetAllMock func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error)
-	UpdateMock func(ctx context.Context, id string, status *string) error
-	CountMock  func(ctx context.Context, filter enrollment.Filters) (int, error)
+	CreateMock      func(ctx context.Context, enroll *domain.Enrollment) error
+	GetAllMock      func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error)
+	GetMock         func(ctx context.Context, id string) (*domain.Enrollment, error)
+	GetWaitlistMock func(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error)
+	// WaitlistPositionMock is optional, when it is nil every waitlisted
+	// enrollment is the first of the queue
+	WaitlistPositionMock func(ctx context.Context, id string) (int, error)
+	UpdateMock           func(ctx context.Context, id string, status, currentStatus *string) error
+	CountMock            func(ctx context.Context, filter enrollment.Filters) (int, error)
+	CountByStatusMock    func(ctx context.Context) (map[domain.EnrollStatus]int, error)
+	DeleteMock           func(ctx context.Context, id string, currentStatus string) error
+	ReactivateMock       func(ctx context.Context, enroll *domain.Enrollment) error
+	// GetCapacityMock is optional, when it is nil no course has a capacity of
+	// its own
+	GetCapacityMock func(ctx context.Context, courseId string) (*int, error)
+	SetCapacityMock func(ctx context.Context, courseId string, seats int) error
+	// LockCourseMock is optional, when it is nil the course is locked
+	LockCourseMock func(ctx context.Context, courseId string) error
+	// TransactionMock is optional, when it is nil fn runs against the mock itself
+	TransactionMock func(ctx context.Context, fn func(repo enrollment.Repository) error) error
 }
 
 func (mock *mockRepository) Create(ctx context.Context, enroll *domain.Enrollment) error {
@@ -22,10 +39,85 @@ func (mock *mockRepository) GetAll(ctx context.Context, filters enrollment.Filte
 	return mock.GetAllMock(ctx, filters, offset, limit)
 }
 
-func (mock *mockRepository) Update(ctx context.Context, id string, status *string) error {
-	return mock.UpdateMock(ctx, id, status)
+func (mock *mockRepository) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	return mock.GetMock(ctx, id)
+}
+
+func (mock *mockRepository) GetWaitlist(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+	return mock.GetWaitlistMock(ctx, courseId, limit)
+}
+
+func (mock *mockRepository) WaitlistPosition(ctx context.Context, id string) (int, error) {
+	if mock.WaitlistPositionMock == nil {
+		return 1, nil
+	}
+	return mock.WaitlistPositionMock(ctx, id)
+}
+
+func (mock *mockRepository) Update(ctx context.Context, id string, status, currentStatus *string) error {
+	return mock.UpdateMock(ctx, id, status, currentStatus)
 }
 
 func (mock *mockRepository) Count(ctx context.Context, filter enrollment.Filters) (int, error) {
 	return mock.CountMock(ctx, filter)
 }
+
+func (mock *mockRepository) CountByStatus(ctx context.Context) (map[domain.EnrollStatus]int, error) {
+	return mock.CountByStatusMock(ctx)
+}
+
+func (mock *mockRepository) Delete(ctx context.Context, id string, currentStatus string) error {
+	return mock.DeleteMock(ctx, id, currentStatus)
+}
+
+func (mock *mockRepository) Reactivate(ctx context.Context, enroll *domain.Enrollment) error {
+	return mock.ReactivateMock(ctx, enroll)
+}
+
+func (mock *mockRepository) GetCapacity(ctx context.Context, courseId string) (*int, error) {
+	if mock.GetCapacityMock == nil {
+		return nil, nil
+	}
+	return mock.GetCapacityMock(ctx, courseId)
+}
+
+func (mock *mockRepository) SetCapacity(ctx context.Context, courseId string, seats int) error {
+	return mock.SetCapacityMock(ctx, courseId, seats)
+}
+
+func (mock *mockRepository) LockCourse(ctx context.Context, courseId string) error {
+	if mock.LockCourseMock == nil {
+		return nil
+	}
+	return mock.LockCourseMock(ctx, courseId)
+}
+
+func (mock *mockRepository) Transaction(ctx context.Context, fn func(repo enrollment.Repository) error) error {
+	if mock.TransactionMock == nil {
+		return fn(mock)
+	}
+	return mock.TransactionMock(ctx, fn)
+}
+
+type mockIdempotency struct {
+	ReserveMock       func(ctx context.Context, subject, key, requestHash string) (*idempotency.Record, error)
+	CompleteMock      func(ctx context.Context, subject, key string, statusCode int, body []byte) error
+	ReleaseMock       func(ctx context.Context, subject, key string) error
+	DeleteExpiredMock func(ctx context.Context) (int, error)
+}
+
+func (mock *mockIdempotency) Reserve(ctx context.Context, subject, key, requestHash string) (*idempotency.Record, error) {
+	return mock.ReserveMock(ctx, subject, key, requestHash)
+}
+
+func (mock *mockIdempotency) Complete(ctx context.Context, subject, key string, statusCode int, body []byte) error {
+	return mock.CompleteMock(ctx, subject, key, statusCode, body)
+}
+
+func (mock *mockIdempotency) Release(ctx context.Context, subject, key string) error {
+	return mock.ReleaseMock(ctx, subject, key)
+}
+
+func (mock *mockIdempotency) DeleteExpired(ctx context.Context) (int, error) {
+	return mock.DeleteExpiredMock(ctx)
+}
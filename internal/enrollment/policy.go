@@ -0,0 +1,147 @@
+package enrollment
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_lib_response/response"
+)
+
+// policy checks that the caller may make the request, returning the request
+// to process, narrowed to the caller when needed, or a 403 response.
+type policy func(ctx context.Context, claims *auth.Claims, request interface{}) (interface{}, error)
+
+// authorize runs the policy with the claims of the caller before calling next.
+// Requests without claims are not checked, authentication is disabled then.
+func authorize(log *slog.Logger, p policy, next Controller) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		claims, ok := auth.FromContext(ctx)
+		if !ok {
+			return next(ctx, request)
+		}
+
+		request, err := p(ctx, claims, request)
+		if err != nil {
+			log.InfoContext(ctx, "request forbidden", "roles", claims.Roles, "reason", err.Error())
+			return nil, err
+		}
+		return next(ctx, request)
+	}
+}
+
+// staffRoles can act on the enrollments of every user.
+var staffRoles = []string{auth.RoleRegistrar, auth.RoleAdmin}
+
+// ownUser returns the user a student acts on, the caller itself when the user
+// is empty, and whether the student may act on it.
+func ownUser(claims *auth.Claims, userId string) (string, bool) {
+	if userId == "" {
+		return claims.Subject, true
+	}
+	return userId, userId == claims.Subject
+}
+
+func forbidden(err error) error {
+	return response.Forbidden(err.Error())
+}
+
+// createPolicy lets students enroll only themselves.
+func createPolicy(_ context.Context, claims *auth.Claims, request interface{}) (interface{}, error) {
+	req := request.(CreateReq)
+	switch {
+	case claims.HasRole(staffRoles...):
+		return req, nil
+	case claims.HasRole(auth.RoleStudent):
+		var ok bool
+		if req.UserId, ok = ownUser(claims, req.UserId); !ok {
+			return nil, forbidden(ErrForbiddenUser)
+		}
+		return req, nil
+	}
+	return nil, forbidden(ErrForbiddenRole)
+}
+
+// createBulkPolicy lets students enroll only themselves in every item.
+func createBulkPolicy(_ context.Context, claims *auth.Claims, request interface{}) (interface{}, error) {
+	req := request.(CreateBulkReq)
+	switch {
+	case claims.HasRole(staffRoles...):
+		return req, nil
+	case claims.HasRole(auth.RoleStudent):
+		items := make([]CreateReq, len(req.Items))
+		for i, item := range req.Items {
+			var ok bool
+			if item.UserId, ok = ownUser(claims, item.UserId); !ok {
+				return nil, forbidden(ErrForbiddenUser)
+			}
+			items[i] = item
+		}
+		req.Items = items
+		return req, nil
+	}
+	return nil, forbidden(ErrForbiddenRole)
+}
+
+// getAllPolicy filters the enrollments of students to their own and lets
+// only admins list the withdrawn enrollments.
+func getAllPolicy(_ context.Context, claims *auth.Claims, request interface{}) (interface{}, error) {
+	req := request.(GetAllReq)
+	if req.IncludeDeleted && !claims.HasRole(auth.RoleAdmin) {
+		return nil, forbidden(ErrForbiddenDeleted)
+	}
+
+	switch {
+	case claims.HasRole(staffRoles...):
+		return req, nil
+	case claims.HasRole(auth.RoleStudent):
+		for _, userId := range req.UserIDs {
+			if userId != claims.Subject {
+				return nil, forbidden(ErrForbiddenUser)
+			}
+		}
+		req.UserIDs = []string{claims.Subject}
+		return req, nil
+	}
+	return nil, forbidden(ErrForbiddenRole)
+}
+
+// ownEnrollmentPolicy lets students act only on their own enrollments. An
+// enrollment that can not be read is left to the endpoint, which answers the
+// not found or the failure.
+func ownEnrollmentPolicy(s Service, id func(request interface{}) string) policy {
+	return func(ctx context.Context, claims *auth.Claims, request interface{}) (interface{}, error) {
+		switch {
+		case claims.HasRole(staffRoles...):
+			return request, nil
+		case claims.HasRole(auth.RoleStudent):
+			enroll, err := s.Get(ctx, id(request))
+			if err == nil && enroll.UserID != claims.Subject {
+				return nil, forbidden(ErrForbiddenUser)
+			}
+			return request, nil
+		}
+		return nil, forbidden(ErrForbiddenRole)
+	}
+}
+
+// updatePolicy lets only admins change the status of an enrollment, any
+// other update follows ownEnrollmentPolicy.
+func updatePolicy(s Service) policy {
+	own := ownEnrollmentPolicy(s, func(request interface{}) string { return request.(UpdateReq).ID })
+	return func(ctx context.Context, claims *auth.Claims, request interface{}) (interface{}, error) {
+		req := request.(UpdateReq)
+		if req.Status != nil && !claims.HasRole(auth.RoleAdmin) {
+			return nil, forbidden(ErrForbiddenStatus)
+		}
+		return own(ctx, claims, req)
+	}
+}
+
+// staffPolicy lets only registrars and admins make the request.
+func staffPolicy(_ context.Context, claims *auth.Claims, request interface{}) (interface{}, error) {
+	if !claims.HasRole(staffRoles...) {
+		return nil, forbidden(ErrForbiddenRole)
+	}
+	return request, nil
+}
@@ -0,0 +1,191 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	courseSdkMock "github.com/JuD4Mo/go_api_web_sdk/course/mock"
+	userSdkMock "github.com/JuD4Mo/go_api_web_sdk/user/mock"
+	"github.com/JuD4Mo/go_lib_response/response"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointsAuthorization(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	service := enrollment.NewService(l, enrollment.NewMemoryRepo(l),
+		&userSdkMock.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		},
+		&courseSdkMock.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}, 0)
+
+	own, _, err := service.Create(context.Background(), "u1", "c1")
+	require.NoError(t, err)
+	other, _, err := service.Create(context.Background(), "u2", "c1")
+	require.NoError(t, err)
+
+	// the token is the role of the caller, u1 is the student
+	verifier := &verifierMock{
+		VerifyMock: func(token string) (*auth.Claims, error) {
+			claims := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u1"}}
+			if token != "none" {
+				claims.Roles = []string{token}
+			}
+			return claims, nil
+		},
+	}
+	endpoints := enrollment.MakeEndpoints(service, enrollment.Config{LimitPage: "10", Auth: verifier})
+
+	as := func(role string) context.Context {
+		return tokenContext("Bearer " + role)
+	}
+
+	assertForbidden := func(t *testing.T, expected error, err error) {
+		t.Helper()
+		require.Error(t, err)
+
+		resp := err.(response.Response)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+		assert.EqualError(t, expected, resp.Error())
+	}
+
+	t.Run("students can only enroll themselves", func(t *testing.T) {
+		_, err := endpoints.Create(as(auth.RoleStudent), enrollment.CreateReq{UserId: "u2", CourseId: "c2"})
+		assertForbidden(t, enrollment.ErrForbiddenUser, err)
+
+		resp, err := endpoints.Create(as(auth.RoleStudent), enrollment.CreateReq{CourseId: "c2"})
+		require.NoError(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusCreated, r.StatusCode())
+		assert.Equal(t, "u1", r.GetData().(*domain.Enrollment).UserID)
+
+		_, err = endpoints.CreateBulk(as(auth.RoleStudent), enrollment.CreateBulkReq{Items: []enrollment.CreateReq{
+			{UserId: "u1", CourseId: "c3"},
+			{UserId: "u2", CourseId: "c3"},
+		}})
+		assertForbidden(t, enrollment.ErrForbiddenUser, err)
+	})
+
+	t.Run("registrars and admins can enroll anyone", func(t *testing.T) {
+		for i, role := range []string{auth.RoleRegistrar, auth.RoleAdmin} {
+			resp, err := endpoints.Create(as(role), enrollment.CreateReq{UserId: "u3", CourseId: []string{"c4", "c5"}[i]})
+			require.NoError(t, err, role)
+			assert.Equal(t, http.StatusCreated, resp.(response.Response).StatusCode(), role)
+		}
+	})
+
+	t.Run("students only list their own enrollments", func(t *testing.T) {
+		resp, err := endpoints.GetAll(as(auth.RoleStudent), enrollment.GetAllReq{})
+		require.NoError(t, err)
+
+		enrollments := resp.(response.Response).GetData().([]domain.Enrollment)
+		require.NotEmpty(t, enrollments)
+		for _, enroll := range enrollments {
+			assert.Equal(t, "u1", enroll.UserID)
+		}
+
+		_, err = endpoints.GetAll(as(auth.RoleStudent), enrollment.GetAllReq{UserIDs: []string{"u1", "u2"}})
+		assertForbidden(t, enrollment.ErrForbiddenUser, err)
+
+		resp, err = endpoints.GetAll(as(auth.RoleRegistrar), enrollment.GetAllReq{UserIDs: []string{"u2"}})
+		require.NoError(t, err)
+		assert.Len(t, resp.(response.Response).GetData().([]domain.Enrollment), 1)
+	})
+
+	t.Run("only admins list the withdrawn enrollments", func(t *testing.T) {
+		for _, role := range []string{auth.RoleStudent, auth.RoleRegistrar} {
+			_, err := endpoints.GetAll(as(role), enrollment.GetAllReq{IncludeDeleted: true})
+			assertForbidden(t, enrollment.ErrForbiddenDeleted, err)
+		}
+
+		resp, err := endpoints.GetAll(as(auth.RoleAdmin), enrollment.GetAllReq{IncludeDeleted: true})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+	})
+
+	t.Run("students only read and delete their own enrollments", func(t *testing.T) {
+		_, err := endpoints.Get(as(auth.RoleStudent), enrollment.GetReq{ID: other.ID})
+		assertForbidden(t, enrollment.ErrForbiddenUser, err)
+
+		_, err = endpoints.Delete(as(auth.RoleStudent), enrollment.DeleteReq{ID: other.ID})
+		assertForbidden(t, enrollment.ErrForbiddenUser, err)
+
+		resp, err := endpoints.Get(as(auth.RoleStudent), enrollment.GetReq{ID: own.ID})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+
+		_, err = endpoints.Get(as(auth.RoleStudent), enrollment.GetReq{ID: "missing"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(response.Response).StatusCode())
+
+		resp, err = endpoints.Get(as(auth.RoleRegistrar), enrollment.GetReq{ID: other.ID})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+	})
+
+	t.Run("only admins change the status", func(t *testing.T) {
+		status := string(domain.Active)
+		for _, role := range []string{auth.RoleStudent, auth.RoleRegistrar} {
+			_, err := endpoints.Update(as(role), enrollment.UpdateReq{ID: other.ID, Status: &status})
+			assertForbidden(t, enrollment.ErrForbiddenStatus, err)
+		}
+
+		resp, err := endpoints.Update(as(auth.RoleAdmin), enrollment.UpdateReq{ID: other.ID, Status: &status})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+	})
+
+	t.Run("students only update their own enrollments", func(t *testing.T) {
+		_, err := endpoints.Update(as(auth.RoleStudent), enrollment.UpdateReq{ID: other.ID})
+		assertForbidden(t, enrollment.ErrForbiddenUser, err)
+
+		resp, err := endpoints.Update(as(auth.RoleStudent), enrollment.UpdateReq{ID: own.ID})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+
+		resp, err = endpoints.Update(as(auth.RoleRegistrar), enrollment.UpdateReq{ID: other.ID})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+	})
+
+	t.Run("students can not read the waitlist", func(t *testing.T) {
+		_, err := endpoints.Waitlist(as(auth.RoleStudent), enrollment.WaitlistReq{CourseID: "c1"})
+		assertForbidden(t, enrollment.ErrForbiddenRole, err)
+
+		_, err = endpoints.Waitlist(as(auth.RoleRegistrar), enrollment.WaitlistReq{CourseID: "c1"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("callers without a known role are forbidden", func(t *testing.T) {
+		for name, call := range map[string]func() (interface{}, error){
+			"create":      func() (interface{}, error) { return endpoints.Create(as("none"), enrollment.CreateReq{CourseId: "c1"}) },
+			"create bulk": func() (interface{}, error) { return endpoints.CreateBulk(as("none"), enrollment.CreateBulkReq{}) },
+			"get all":     func() (interface{}, error) { return endpoints.GetAll(as("none"), enrollment.GetAllReq{}) },
+			"get":         func() (interface{}, error) { return endpoints.Get(as("guest"), enrollment.GetReq{ID: own.ID}) },
+			"delete":      func() (interface{}, error) { return endpoints.Delete(as("guest"), enrollment.DeleteReq{ID: own.ID}) },
+			"update":      func() (interface{}, error) { return endpoints.Update(as("none"), enrollment.UpdateReq{ID: own.ID}) },
+		} {
+			_, err := call()
+			require.Error(t, err, name)
+
+			var resp response.Response
+			require.True(t, errors.As(err, &resp), name)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode(), name)
+		}
+	})
+}
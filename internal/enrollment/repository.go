@@ -2,27 +2,86 @@ package enrollment
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"time"
 
 	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type (
 	Repository interface {
 		Create(ctx context.Context, enroll *domain.Enrollment) error
 		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error)
-		Update(ctx context.Context, id string, status *string) error
+		Get(ctx context.Context, id string) (*domain.Enrollment, error)
+		// GetWaitlist returns the waitlisted enrollments of a course in queue
+		// order, a limit lower or equal to zero returns all of them.
+		GetWaitlist(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error)
+		// WaitlistPosition returns the place in the queue of its course of a
+		// waitlisted enrollment, ErrNotFound when it is not waitlisted.
+		WaitlistPosition(ctx context.Context, id string) (int, error)
+		Update(ctx context.Context, id string, status, currentStatus *string) error
 		Count(ctx context.Context, filter Filters) (int, error)
+		// CountByStatus returns the number of enrollments of every status,
+		// withdrawn ones included, the statuses without enrollments are left
+		// out.
+		CountByStatus(ctx context.Context) (map[domain.EnrollStatus]int, error)
+		// Delete soft-deletes the enrollment, marking it as Withdrawn, as long
+		// as it is still in currentStatus.
+		Delete(ctx context.Context, id string, currentStatus string) error
+		// Reactivate enrolls again the user of a withdrawn enrollment, which
+		// takes the status of enroll and starts over as if created now. It
+		// returns ErrStatusConflict when the enrollment is no longer withdrawn.
+		Reactivate(ctx context.Context, enroll *domain.Enrollment) error
+		// GetCapacity returns the seats of the course, nil when the course has
+		// no capacity of its own.
+		GetCapacity(ctx context.Context, courseId string) (*int, error)
+		// SetCapacity stores the seats of the course, replacing the previous
+		// value.
+		SetCapacity(ctx context.Context, courseId string, seats int) error
+		// LockCourse serializes the transactions that take or free seats of
+		// the course, the lock is held until the transaction ends. It does
+		// nothing outside Transaction.
+		LockCourse(ctx context.Context, courseId string) error
+		// Transaction runs fn inside a database transaction. The repository
+		// handed to fn locks the rows it reads until the transaction ends.
+		Transaction(ctx context.Context, fn func(repo Repository) error) error
 	}
 
 	repo struct {
 		db  *gorm.DB
-		log *log.Logger
+		log *slog.Logger
+		// lock is set on the repositories handed to Transaction, their reads
+		// lock the rows until the transaction ends
+		lock bool
+	}
+
+	// courseLock is a row of the course_locks table.
+	courseLock struct {
+		CourseID string `gorm:"type:varchar(36);primaryKey"`
+	}
+
+	// courseCapacity is a row of the course_capacities table.
+	courseCapacity struct {
+		CourseID  string `gorm:"type:varchar(36);primaryKey"`
+		Seats     int    `gorm:"not null"`
+		CreatedAt *time.Time
+		UpdatedAt *time.Time
 	}
 )
 
-func NewRepo(db *gorm.DB, log *log.Logger) Repository {
+func (courseCapacity) TableName() string {
+	return "course_capacities"
+}
+
+func (courseLock) TableName() string {
+	return "course_locks"
+}
+
+func NewRepo(db *gorm.DB, log *slog.Logger) Repository {
 	return &repo{
 		db:  db,
 		log: log,
@@ -30,10 +89,27 @@ func NewRepo(db *gorm.DB, log *log.Logger) Repository {
 
 }
 
+// newLockingRepo builds the repository used inside a transaction.
+func newLockingRepo(db *gorm.DB, log *slog.Logger) Repository {
+	return &repo{
+		db:   db,
+		log:  log,
+		lock: true,
+	}
+}
+
 func (repo *repo) Create(ctx context.Context, enroll *domain.Enrollment) error {
-	result := repo.db.WithContext(ctx).Create(enroll)
-	if result.Error != nil {
-		return result.Error
+	// inside a transaction GORM runs the insert in a savepoint, Postgres aborts
+	// the whole transaction on a failed statement and the duplicate could not
+	// be looked up afterwards
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(enroll).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return repo.alreadyEnrolled(ctx, enroll.UserID, enroll.CourseID)
+		}
+		return err
 	}
 	return nil
 }
@@ -41,32 +117,108 @@ func (repo *repo) Create(ctx context.Context, enroll *domain.Enrollment) error {
 func (repo *repo) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error) {
 	var e []domain.Enrollment
 
-	tx := repo.db.WithContext(ctx).Model(&e)
+	tx := repo.read(ctx).Model(&e)
 	tx = applyFilters(tx, filters)
+	if filters.After != nil {
+		// the leading created_at <= ? lets the (created_at, id) index be used as a range
+		tx = tx.Where("created_at <= ? AND (created_at < ? OR id < ?)",
+			filters.After.CreatedAt, filters.After.CreatedAt, filters.After.ID)
+	}
 	tx = tx.Limit(limit).Offset(offset)
-	result := tx.Order("created_at desc").Find(&e)
+	tx = applySort(tx, filters.Sort)
+	result := tx.Find(&e)
 
 	if result.Error != nil {
-		repo.log.Println(result.Error)
+		repo.log.ErrorContext(ctx, "listing enrollments", logging.Err(result.Error))
 		return nil, result.Error
 	}
 	return e, nil
 }
-func (repo *repo) Update(ctx context.Context, id string, status *string) error {
+
+func (repo *repo) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	var enroll domain.Enrollment
+
+	result := repo.read(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&enroll)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			repo.log.InfoContext(ctx, "enrollment not found", logging.EnrollmentIDKey, id)
+			return nil, ErrNotFound{EnrollmentId: id}
+		}
+		repo.log.ErrorContext(ctx, "getting enrollment", logging.EnrollmentIDKey, id, logging.Err(result.Error))
+		return nil, result.Error
+	}
+
+	return &enroll, nil
+}
+
+func (repo *repo) GetWaitlist(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+	var e []domain.Enrollment
+
+	tx := repo.read(ctx).Model(&e).Where("course_id = ? AND status = ? AND deleted_at IS NULL", courseId, Waitlisted)
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	result := tx.Order("created_at asc, id asc").Find(&e)
+
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "getting waitlist", logging.CourseIDKey, courseId, logging.Err(result.Error))
+		return nil, result.Error
+	}
+	return e, nil
+}
+
+func (repo *repo) WaitlistPosition(ctx context.Context, id string) (int, error) {
+	var enroll domain.Enrollment
+
+	result := repo.db.WithContext(ctx).Where("id = ? AND status = ? AND deleted_at IS NULL", id, Waitlisted).First(&enroll)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			repo.log.InfoContext(ctx, "waitlisted enrollment not found", logging.EnrollmentIDKey, id)
+			return 0, ErrNotFound{EnrollmentId: id}
+		}
+		repo.log.ErrorContext(ctx, "getting waitlisted enrollment", logging.EnrollmentIDKey, id, logging.Err(result.Error))
+		return 0, result.Error
+	}
+
+	// the enrollments ahead in the queue, in the order of GetWaitlist
+	var ahead int64
+	result = repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Where("course_id = ? AND status = ? AND deleted_at IS NULL", enroll.CourseID, Waitlisted).
+		Where("created_at < ? OR (created_at = ? AND id < ?)", enroll.CreatedAt, enroll.CreatedAt, enroll.ID).
+		Count(&ahead)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "counting waitlist", logging.EnrollmentIDKey, id, logging.Err(result.Error))
+		return 0, result.Error
+	}
+
+	return int(ahead) + 1, nil
+}
+
+func (repo *repo) Update(ctx context.Context, id string, status, currentStatus *string) error {
 	values := make(map[string]interface{})
 
 	if status != nil {
 		values["status"] = *status
 	}
 
-	result := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).Where("id = ?", id).Updates(values)
+	tx := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).Where("id = ?", id)
+	if currentStatus != nil {
+		tx = tx.Where("status = ?", *currentStatus)
+	}
+
+	result := tx.Updates(values)
 	if result.Error != nil {
-		repo.log.Println(result.Error)
+		repo.log.ErrorContext(ctx, "updating enrollment", logging.EnrollmentIDKey, id, logging.Err(result.Error))
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		repo.log.Printf("enrollment %s does not exists", id)
+		if currentStatus != nil {
+			repo.log.InfoContext(ctx, "enrollment is no longer in the expected status", logging.EnrollmentIDKey, id, "status", *currentStatus)
+			return ErrStatusConflict{EnrollmentId: id, Status: *currentStatus}
+		}
+
+		repo.log.InfoContext(ctx, "enrollment not found", logging.EnrollmentIDKey, id)
 		return ErrNotFound{EnrollmentId: id}
 	}
 
@@ -76,19 +228,177 @@ func (repo *repo) Update(ctx context.Context, id string, status *string) error {
 func (repo *repo) Count(ctx context.Context, filters Filters) (int, error) {
 	var count int64
 
-	tx := repo.db.WithContext(ctx).Model(&domain.Enrollment{})
+	tx := repo.read(ctx).Model(&domain.Enrollment{})
 	tx = applyFilters(tx, filters)
 
-	result := tx.Count(&count)
+	var result *gorm.DB
+	if repo.lock {
+		// Postgres does not allow FOR UPDATE with aggregates, the locked ids
+		// are counted instead
+		var ids []string
+		result = tx.Pluck("id", &ids)
+		count = int64(len(ids))
+	} else {
+		result = tx.Count(&count)
+	}
 
 	if result.Error != nil {
-		repo.log.Println(result.Error)
+		repo.log.ErrorContext(ctx, "counting enrollments", logging.Err(result.Error))
 		return 0, result.Error
 	}
 
 	return int(count), nil
 }
 
+func (repo *repo) CountByStatus(ctx context.Context) (map[domain.EnrollStatus]int, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+
+	result := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "counting enrollments by status", logging.Err(result.Error))
+		return nil, result.Error
+	}
+
+	counts := make(map[domain.EnrollStatus]int, len(rows))
+	for _, row := range rows {
+		counts[domain.EnrollStatus(row.Status)] = row.Count
+	}
+	return counts, nil
+}
+
+func (repo *repo) Delete(ctx context.Context, id string, currentStatus string) error {
+	values := map[string]interface{}{
+		"status":     string(Withdrawn),
+		"deleted_at": time.Now(),
+	}
+
+	result := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Where("id = ? AND status = ? AND deleted_at IS NULL", id, currentStatus).
+		Updates(values)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "deleting enrollment", logging.EnrollmentIDKey, id, logging.Err(result.Error))
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		repo.log.InfoContext(ctx, "enrollment is no longer in the expected status", logging.EnrollmentIDKey, id, "status", currentStatus)
+		return ErrStatusConflict{EnrollmentId: id, Status: currentStatus}
+	}
+
+	return nil
+}
+
+func (repo *repo) Reactivate(ctx context.Context, enroll *domain.Enrollment) error {
+	now := time.Now()
+	values := map[string]interface{}{
+		"status":     string(enroll.Status),
+		"deleted_at": nil,
+		"created_at": now,
+		"updated_at": now,
+	}
+
+	result := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Where("id = ? AND status = ?", enroll.ID, Withdrawn).
+		Updates(values)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "reactivating enrollment", logging.EnrollmentIDKey, enroll.ID, logging.Err(result.Error))
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		repo.log.InfoContext(ctx, "enrollment is no longer in the expected status", logging.EnrollmentIDKey, enroll.ID, "status", Withdrawn)
+		return ErrStatusConflict{EnrollmentId: enroll.ID, Status: string(Withdrawn)}
+	}
+
+	enroll.CreatedAt, enroll.UpdatedAt = &now, &now
+	return nil
+}
+
+func (repo *repo) GetCapacity(ctx context.Context, courseId string) (*int, error) {
+	var capacity courseCapacity
+
+	result := repo.read(ctx).Where("course_id = ?", courseId).First(&capacity)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		repo.log.ErrorContext(ctx, "getting course capacity", logging.CourseIDKey, courseId, logging.Err(result.Error))
+		return nil, result.Error
+	}
+
+	return &capacity.Seats, nil
+}
+
+func (repo *repo) SetCapacity(ctx context.Context, courseId string, seats int) error {
+	result := repo.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "course_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"seats", "updated_at"}),
+	}).Create(&courseCapacity{CourseID: courseId, Seats: seats})
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "setting course capacity", logging.CourseIDKey, courseId, logging.Err(result.Error))
+		return result.Error
+	}
+
+	return nil
+}
+
+// LockCourse upserts the row of the course in course_locks, the update locks
+// it even when the row is new. The locks taken by the reads only cover the
+// rows that exist, under read committed a transaction that waited for them
+// would still count the seats without the row inserted by the other one.
+func (repo *repo) LockCourse(ctx context.Context, courseId string) error {
+	if !repo.lock {
+		return nil
+	}
+
+	result := repo.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "course_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"course_id": courseId}),
+	}).Create(&courseLock{CourseID: courseId})
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "locking course", logging.CourseIDKey, courseId, logging.Err(result.Error))
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *repo) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(newLockingRepo(tx, repo.log))
+	})
+}
+
+// read starts a select, locking the rows it returns when the repository is
+// part of a transaction.
+func (repo *repo) read(ctx context.Context) *gorm.DB {
+	tx := repo.db.WithContext(ctx)
+	if repo.lock {
+		tx = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	return tx
+}
+
+// alreadyEnrolled builds the error returned when the unique (user_id, course_id)
+// index rejects an insert, looking up the enrollment that holds the pair.
+func (repo *repo) alreadyEnrolled(ctx context.Context, userId, courseId string) error {
+	var existing domain.Enrollment
+
+	result := repo.db.WithContext(ctx).Where("user_id = ? AND course_id = ?", userId, courseId).First(&existing)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "getting existing enrollment", logging.UserIDKey, userId, logging.CourseIDKey, courseId, logging.Err(result.Error))
+		return result.Error
+	}
+
+	return ErrAlreadyEnrolled{EnrollmentId: existing.ID, UserId: userId, CourseId: courseId}
+}
+
 func applyFilters(tx *gorm.DB, filters Filters) *gorm.DB {
 	if filters.UserId != "" {
 		tx = tx.Where("user_id = ?", filters.UserId)
@@ -96,6 +406,43 @@ func applyFilters(tx *gorm.DB, filters Filters) *gorm.DB {
 	if filters.CourseId != "" {
 		tx = tx.Where("course_id = ?", filters.CourseId)
 	}
+	if len(filters.UserIds) > 0 {
+		tx = tx.Where("user_id IN ?", filters.UserIds)
+	}
+	if len(filters.CourseIds) > 0 {
+		tx = tx.Where("course_id IN ?", filters.CourseIds)
+	}
+	if filters.CreatedFrom != nil {
+		tx = tx.Where("created_at >= ?", *filters.CreatedFrom)
+	}
+	if filters.CreatedTo != nil {
+		tx = tx.Where("created_at <= ?", *filters.CreatedTo)
+	}
+	if len(filters.Statuses) > 0 {
+		tx = tx.Where("status IN ?", filters.Statuses)
+	}
+	if !filters.IncludeDeleted {
+		tx = tx.Where("deleted_at IS NULL")
+	}
 
 	return tx
 }
+
+// applySort orders by the requested columns, the id is always added last so
+// rows with the same values keep a stable order between pages.
+func applySort(tx *gorm.DB, sort []SortField) *gorm.DB {
+	if len(sort) == 0 {
+		sort = []SortField{{Column: "created_at", Desc: true}}
+	}
+
+	hasID := false
+	for _, field := range sort {
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Column}, Desc: field.Desc})
+		hasID = hasID || field.Column == "id"
+	}
+
+	if !hasID {
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: sort[0].Desc})
+	}
+	return tx
+}
@@ -0,0 +1,81 @@
+package enrollment_test
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment/enrollmenttest"
+	"github.com/JuD4Mo/go_api_web_enrollment/pkg/bootstrap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRepositoryConformance(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("gorm on sqlite", func(t *testing.T) {
+		enrollmenttest.Run(t, func(t *testing.T) enrollment.Repository {
+			db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+				TranslateError: true,
+				Logger:         logger.Discard,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// every connection to :memory: is a new database
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlDB.SetMaxOpenConns(1)
+			t.Cleanup(func() { _ = sqlDB.Close() })
+
+			if err := bootstrap.Migrate(db, l); err != nil {
+				t.Fatal(err)
+			}
+			return enrollment.NewRepo(db, l)
+		})
+	})
+
+	// REPOSITORY_TEST_DRIVER runs the suite against a mysql or postgres database
+	// configured with the DATABASE_* envs, its tables are emptied by every test
+	if driver := os.Getenv("REPOSITORY_TEST_DRIVER"); driver != "" {
+		t.Run("gorm on "+driver, func(t *testing.T) {
+			dialector, err := bootstrap.Dialector(driver)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := gorm.Open(dialector, &gorm.Config{
+				TranslateError: true,
+				Logger:         logger.Discard,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := bootstrap.Migrate(db, l); err != nil {
+				t.Fatal(err)
+			}
+
+			enrollmenttest.Run(t, func(t *testing.T) enrollment.Repository {
+				for _, table := range []string{"enrollments", "course_capacities", "course_locks"} {
+					if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+						t.Fatal(err)
+					}
+				}
+				return enrollment.NewRepo(db, l)
+			})
+		})
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		enrollmenttest.Run(t, func(t *testing.T) enrollment.Repository {
+			return enrollment.NewMemoryRepo(l)
+		})
+	})
+}
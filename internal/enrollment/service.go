@@ -2,67 +2,177 @@ package enrollment
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
 	courseSDK "github.com/JuD4Mo/go_api_web_sdk/course"
 	userSDK "github.com/JuD4Mo/go_api_web_sdk/user"
 )
 
 type (
 	Service interface {
-		Create(ctx context.Context, userId, courseId string) (*domain.Enrollment, error)
+		// Create enrolls the user in the course, position is the place of the
+		// enrollment in the waitlist of the course, zero when it took a seat.
+		Create(ctx context.Context, userId, courseId string) (enroll *domain.Enrollment, position int, err error)
+		CreateBulk(ctx context.Context, items []BulkItem, atomic bool) ([]BulkResult, error)
 		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error)
+		Get(ctx context.Context, id string) (*domain.Enrollment, error)
 		Update(ctx context.Context, id string, status *string) error
 		Count(ctx context.Context, filters Filters) (int, error)
+		// CountByStatus returns the number of enrollments of every status,
+		// withdrawn ones included.
+		CountByStatus(ctx context.Context) (map[domain.EnrollStatus]int, error)
+		Delete(ctx context.Context, id string) error
+		Waitlist(ctx context.Context, courseId string) ([]WaitlistEntry, error)
+		SetCapacity(ctx context.Context, courseId string, seats int) error
 	}
 
 	service struct {
-		log             *log.Logger
+		log             *slog.Logger
 		repo            Repository
 		userTransport   userSDK.Transport
 		courseTransport courseSDK.Transport
+		capacity        int
 	}
 
 	Filters struct {
-		UserId   string
-		CourseId string
+		UserId      string
+		CourseId    string
+		UserIds     []string
+		CourseIds   []string
+		Statuses    []string
+		CreatedFrom *time.Time
+		CreatedTo   *time.Time
+		// IncludeDeleted also returns the withdrawn (soft-deleted) enrollments
+		IncludeDeleted bool
+		// Sort is the order of the results of GetAll, newest first when empty
+		Sort []SortField
+		// After makes GetAll return the enrollments following the cursor,
+		// it can not be combined with Sort
+		After *Cursor
+	}
+
+	SortField struct {
+		Column string
+		Desc   bool
+	}
+
+	WaitlistEntry struct {
+		Position int `json:"position"`
+		domain.Enrollment
 	}
 )
 
-func NewService(log *log.Logger, repo Repository, userTransport userSDK.Transport, courseTransport courseSDK.Transport) Service {
+// seatStatuses are the statuses that take one of the course's seats.
+var seatStatuses = []string{string(domain.Pending), string(domain.Active), string(domain.Studying)}
+
+// NewService builds the enrollment service. capacity is the maximum number of
+// seats of the courses without a capacity of their own, a value lower or
+// equal to zero means no limit.
+func NewService(log *slog.Logger, repo Repository, userTransport userSDK.Transport, courseTransport courseSDK.Transport, capacity int) Service {
 	return &service{
 		log:             log,
 		repo:            repo,
 		userTransport:   userTransport,
 		courseTransport: courseTransport,
+		capacity:        capacity,
 	}
 }
 
-func (s service) Create(ctx context.Context, userId, courseId string) (*domain.Enrollment, error) {
+func (s service) Create(ctx context.Context, userId, courseId string) (_ *domain.Enrollment, position int, err error) {
+	ctx, span := startSpan(ctx, "enrollment.Create")
+	defer func() { endSpan(span, err) }()
+	ctx = logging.With(ctx, logging.UserIDKey, userId, logging.CourseIDKey, courseId)
+
 	enroll := &domain.Enrollment{
 		UserID:   userId,
 		CourseID: courseId,
 		Status:   domain.Pending,
 	}
 
-	_, err := s.userTransport.Get(userId)
+	_, err = s.getUser(ctx, userId)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	_, err = s.courseTransport.Get(courseId)
+	_, err = s.getCourse(ctx, courseId)
 	if err != nil {
-		s.log.Println(err)
-		return nil, err
+		s.log.WarnContext(ctx, "getting course", logging.Err(err))
+		return nil, 0, err
 	}
 
-	err = s.repo.Create(ctx, enroll)
+	err = s.repo.Transaction(ctx, func(repo Repository) error {
+		if err := s.insert(ctx, repo, enroll); err != nil {
+			return err
+		}
+
+		if enroll.Status != Waitlisted {
+			return nil
+		}
+
+		// the position is read before the commit, no one can be promoted
+		// from the queue meanwhile
+		position, err = repo.WaitlistPosition(ctx, enroll.ID)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return enroll, nil
+	s.log.InfoContext(ctx, "enrollment created", logging.EnrollmentIDKey, enroll.ID, "status", enroll.Status, "position", position)
+	return enroll, position, nil
+}
+
+// insert stores the enrollment once it is checked that the user is not
+// enrolled yet, waitlisting it when the course has no seats left. A user who
+// withdrew from the course gets the withdrawn enrollment back.
+func (s service) insert(ctx context.Context, repo Repository, enroll *domain.Enrollment) error {
+	// the seats are counted and taken under the course lock, otherwise two
+	// transactions could both see the last free seat
+	if err := repo.LockCourse(ctx, enroll.CourseID); err != nil {
+		return err
+	}
+
+	existing, err := repo.GetAll(ctx, Filters{UserId: enroll.UserID, CourseId: enroll.CourseID, IncludeDeleted: true}, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	// the unique (user_id, course_id) index keeps the withdrawn enrollment,
+	// it is reactivated instead of inserting a new one
+	reactivate := false
+	if len(existing) > 0 {
+		if existing[0].Status != Withdrawn {
+			return ErrAlreadyEnrolled{EnrollmentId: existing[0].ID, UserId: enroll.UserID, CourseId: enroll.CourseID}
+		}
+		enroll.ID, reactivate = existing[0].ID, true
+	}
+
+	capacity, err := s.seats(ctx, repo, enroll.CourseID)
+	if err != nil {
+		return err
+	}
+
+	if capacity > 0 {
+		taken, err := repo.Count(ctx, Filters{CourseId: enroll.CourseID, Statuses: seatStatuses})
+		if err != nil {
+			return err
+		}
+
+		if taken >= capacity {
+			enroll.Status = Waitlisted
+			s.log.InfoContext(ctx, "waitlisting enrollment",
+				logging.Err(ErrCourseFull{CourseId: enroll.CourseID, Capacity: capacity}))
+		}
+	}
+
+	if reactivate {
+		s.log.InfoContext(ctx, "reactivating withdrawn enrollment", logging.EnrollmentIDKey, enroll.ID)
+		return repo.Reactivate(ctx, enroll)
+	}
+	return repo.Create(ctx, enroll)
 }
 
 func (s service) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error) {
@@ -73,22 +183,203 @@ func (s service) GetAll(ctx context.Context, filters Filters, offset, limit int)
 	return enrollments, nil
 }
 
+func (s service) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	ctx = logging.With(ctx, logging.EnrollmentIDKey, id)
+	enroll, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return enroll, nil
+}
+
 func (s service) Update(ctx context.Context, id string, status *string) error {
+	ctx = logging.With(ctx, logging.EnrollmentIDKey, id)
+	if status == nil {
+		return s.repo.Update(ctx, id, nil, nil)
+	}
+
+	if !validStatus(domain.EnrollStatus(*status)) {
+		return ErrInvalidStatus{*status}
+	}
 
-	if status != nil {
-		switch domain.EnrollStatus(*status) {
-		case domain.Pending, domain.Active, domain.Studying:
-		default:
-			return ErrInvalidStatus{*status}
+	enroll, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	ctx = logging.With(ctx, logging.UserIDKey, enroll.UserID, logging.CourseIDKey, enroll.CourseID)
+
+	if !canTransition(enroll.Status, domain.EnrollStatus(*status)) {
+		return ErrInvalidTransition{From: string(enroll.Status), To: *status}
+	}
+
+	// a withdrawal is a delete whichever endpoint asks for it
+	if domain.EnrollStatus(*status) == Withdrawn {
+		return s.withdraw(ctx, enroll)
+	}
+
+	current := string(enroll.Status)
+	return s.repo.Transaction(ctx, func(repo Repository) error {
+		if err := repo.Update(ctx, id, status, &current); err != nil {
+			return err
 		}
+
+		if holdsSeat(enroll.Status) && !holdsSeat(domain.EnrollStatus(*status)) {
+			return s.promote(ctx, repo, enroll.CourseID)
+		}
+		return nil
+	})
+}
+
+func (s service) Delete(ctx context.Context, id string) error {
+	ctx = logging.With(ctx, logging.EnrollmentIDKey, id)
+	enroll, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
 	}
+	ctx = logging.With(ctx, logging.UserIDKey, enroll.UserID, logging.CourseIDKey, enroll.CourseID)
 
-	if err := s.repo.Update(ctx, id, status); err != nil {
+	if !canTransition(enroll.Status, Withdrawn) {
+		return ErrInvalidTransition{From: string(enroll.Status), To: string(Withdrawn)}
+	}
+
+	return s.withdraw(ctx, enroll)
+}
+
+// withdraw soft-deletes the enrollment, giving its seat to the waitlist.
+func (s service) withdraw(ctx context.Context, enroll *domain.Enrollment) error {
+	return s.repo.Transaction(ctx, func(repo Repository) error {
+		if err := repo.Delete(ctx, enroll.ID, string(enroll.Status)); err != nil {
+			return err
+		}
+
+		if holdsSeat(enroll.Status) {
+			return s.promote(ctx, repo, enroll.CourseID)
+		}
+		return nil
+	})
+}
+
+// promote moves the first waitlisted enrollment of the course to Pending when
+// the course has a free seat.
+func (s service) promote(ctx context.Context, repo Repository, courseId string) error {
+	if err := repo.LockCourse(ctx, courseId); err != nil {
 		return err
 	}
+
+	capacity, err := s.seats(ctx, repo, courseId)
+	if err != nil {
+		return err
+	}
+
+	if capacity <= 0 {
+		return nil
+	}
+
+	taken, err := repo.Count(ctx, Filters{CourseId: courseId, Statuses: seatStatuses})
+	if err != nil {
+		return err
+	}
+
+	if taken >= capacity {
+		return nil
+	}
+
+	next, err := repo.GetWaitlist(ctx, courseId, 1)
+	if err != nil {
+		return err
+	}
+
+	if len(next) == 0 {
+		return nil
+	}
+
+	pending, waitlisted := string(domain.Pending), string(Waitlisted)
+	if err := repo.Update(ctx, next[0].ID, &pending, &waitlisted); err != nil {
+		return err
+	}
+
+	s.log.InfoContext(ctx, "enrollment promoted from the waitlist",
+		logging.EnrollmentIDKey, next[0].ID, logging.UserIDKey, next[0].UserID)
 	return nil
 }
 
+// seats returns the capacity of the course, the default capacity of the
+// service when the course has none of its own.
+func (s service) seats(ctx context.Context, repo Repository, courseId string) (int, error) {
+	capacity, err := repo.GetCapacity(ctx, courseId)
+	if err != nil {
+		return 0, err
+	}
+
+	if capacity == nil {
+		return s.capacity, nil
+	}
+	return *capacity, nil
+}
+
 func (s service) Count(ctx context.Context, filters Filters) (int, error) {
 	return s.repo.Count(ctx, filters)
 }
+
+func (s service) CountByStatus(ctx context.Context) (map[domain.EnrollStatus]int, error) {
+	return s.repo.CountByStatus(ctx)
+}
+
+func (s service) Waitlist(ctx context.Context, courseId string) ([]WaitlistEntry, error) {
+	ctx = logging.With(ctx, logging.CourseIDKey, courseId)
+	enrollments, err := s.repo.GetWaitlist(ctx, courseId, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]WaitlistEntry, len(enrollments))
+	for i, e := range enrollments {
+		entries[i] = WaitlistEntry{Position: i + 1, Enrollment: e}
+	}
+	return entries, nil
+}
+
+func (s service) SetCapacity(ctx context.Context, courseId string, seats int) error {
+	ctx = logging.With(ctx, logging.CourseIDKey, courseId)
+	if seats <= 0 {
+		return ErrInvalidSeats
+	}
+
+	if _, err := s.getCourse(ctx, courseId); err != nil {
+		return err
+	}
+
+	// the seats added to the course go to the waitlisted enrollments
+	return s.repo.Transaction(ctx, func(repo Repository) error {
+		if err := repo.LockCourse(ctx, courseId); err != nil {
+			return err
+		}
+
+		if err := repo.SetCapacity(ctx, courseId, seats); err != nil {
+			return err
+		}
+
+		taken, err := repo.Count(ctx, Filters{CourseId: courseId, Statuses: seatStatuses})
+		if err != nil {
+			return err
+		}
+
+		for ; taken < seats; taken++ {
+			waitlist, err := repo.GetWaitlist(ctx, courseId, 1)
+			if err != nil {
+				return err
+			}
+			if len(waitlist) == 0 {
+				break
+			}
+
+			if err := s.promote(ctx, repo, courseId); err != nil {
+				return err
+			}
+		}
+
+		s.log.InfoContext(ctx, "course capacity set", "seats", seats)
+		return nil
+	})
+}
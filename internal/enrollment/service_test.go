@@ -3,21 +3,25 @@ package enrollment_test
 import (
 	"context"
 	"errors"
-	"io"
-	"log"
+	"fmt"
+	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/JuD4Mo/go_api_web_domain/domain"
 	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	courseSDK "github.com/JuD4Mo/go_api_web_sdk/course"
 	courseSdk "github.com/JuD4Mo/go_api_web_sdk/course/mock"
+	userSdkErr "github.com/JuD4Mo/go_api_web_sdk/user"
 	userSdk "github.com/JuD4Mo/go_api_web_sdk/user/mock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServiceGetAll(t *testing.T) {
 
 	//Grupo de pruebas dentro del test
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.DiscardHandler)
 	count := 0
 	expectedCounter := 1
 	t.Run("should return an error", func(t *testing.T) {
@@ -29,7 +33,7 @@ func TestServiceGetAll(t *testing.T) {
 			},
 		}
 
-		service := enrollment.NewService(l, repo, nil, nil)
+		service := enrollment.NewService(l, repo, nil, nil, 0)
 
 		enrollments, err := service.GetAll(context.Background(), enrollment.Filters{}, 0, 10)
 		assert.Error(t, err)
@@ -61,7 +65,7 @@ func TestServiceGetAll(t *testing.T) {
 			},
 		}
 
-		service := enrollment.NewService(l, repo, nil, nil)
+		service := enrollment.NewService(l, repo, nil, nil, 0)
 
 		enrollments, err := service.GetAll(context.Background(), enrollment.Filters{}, 0, 10)
 
@@ -71,18 +75,63 @@ func TestServiceGetAll(t *testing.T) {
 	})
 }
 
+func TestService_Get(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("should return an error", func(t *testing.T) {
+		expectedErr := enrollment.ErrNotFound{EnrollmentId: "1"}
+		repo := &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return nil, enrollment.ErrNotFound{EnrollmentId: id}
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 0)
+
+		enroll, err := service.Get(context.Background(), "1")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, expectedErr, err)
+		assert.Nil(t, enroll)
+	})
+
+	t.Run("should return the enrollment", func(t *testing.T) {
+		expectedCounter := 1
+		count := 0
+		expectedData := &domain.Enrollment{ID: "1", UserID: "11", CourseID: "22", Status: "P"}
+		repo := &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				count++
+				assert.Equal(t, "1", id)
+				return &domain.Enrollment{ID: "1", UserID: "11", CourseID: "22", Status: "P"}, nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 0)
+
+		enroll, err := service.Get(context.Background(), "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, expectedCounter, count)
+		assert.Equal(t, expectedData, enroll)
+	})
+}
+
 func TestService_Update(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.DiscardHandler)
 
 	t.Run("should return an error", func(t *testing.T) {
 		expectedErr := errors.New("some error")
 		repo := &mockRepository{
-			UpdateMock: func(ctx context.Context, id string, status *string) error {
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: domain.Pending}, nil
+			},
+			UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
 				return errors.New("some error")
 			},
 		}
 
-		service := enrollment.NewService(l, repo, nil, nil)
+		service := enrollment.NewService(l, repo, nil, nil, 0)
 
 		status := "A"
 		err := service.Update(context.Background(), "11", &status)
@@ -98,16 +147,21 @@ func TestService_Update(t *testing.T) {
 		expetectedId := "1"
 		expetectedStatus := "A"
 		repo := &mockRepository{
-			UpdateMock: func(ctx context.Context, id string, status *string) error {
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: domain.Pending}, nil
+			},
+			UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
 				count++
 				assert.Equal(t, expetectedId, id)
 				assert.NotNil(t, status)
 				assert.Equal(t, expetectedStatus, *status)
+				assert.NotNil(t, currentStatus)
+				assert.Equal(t, "P", *currentStatus)
 				return nil
 			},
 		}
 
-		service := enrollment.NewService(l, repo, nil, nil)
+		service := enrollment.NewService(l, repo, nil, nil, 0)
 
 		status := "A"
 		err := service.Update(context.Background(), "1", &status)
@@ -115,10 +169,272 @@ func TestService_Update(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, expectedCounter, count)
 	})
+
+	t.Run("should return an invalid status error", func(t *testing.T) {
+		service := enrollment.NewService(l, &mockRepository{}, nil, nil, 0)
+
+		status := "X"
+		err := service.Update(context.Background(), "1", &status)
+
+		assert.Equal(t, enrollment.ErrInvalidStatus{Status: "X"}, err)
+	})
+
+	t.Run("should reject a transition that is not allowed", func(t *testing.T) {
+		tests := []struct {
+			from domain.EnrollStatus
+			to   string
+		}{
+			{from: domain.Studying, to: "P"},
+			{from: domain.Active, to: "P"},
+			{from: domain.Pending, to: "S"},
+			{from: enrollment.Completed, to: "W"},
+			{from: enrollment.Withdrawn, to: "A"},
+			{from: enrollment.Rejected, to: "A"},
+		}
+
+		for _, tt := range tests {
+			repo := &mockRepository{
+				GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+					return &domain.Enrollment{ID: id, Status: tt.from}, nil
+				},
+			}
+
+			service := enrollment.NewService(l, repo, nil, nil, 0)
+
+			status := tt.to
+			err := service.Update(context.Background(), "1", &status)
+
+			assert.Equal(t, enrollment.ErrInvalidTransition{From: string(tt.from), To: tt.to}, err)
+		}
+	})
+
+	t.Run("should allow every transition in the state machine", func(t *testing.T) {
+		tests := []struct {
+			from domain.EnrollStatus
+			to   string
+		}{
+			{from: domain.Pending, to: "A"},
+			{from: domain.Pending, to: "R"},
+			{from: domain.Pending, to: "W"},
+			{from: domain.Active, to: "S"},
+			{from: domain.Active, to: "W"},
+			{from: domain.Studying, to: "C"},
+			{from: domain.Studying, to: "W"},
+		}
+
+		for _, tt := range tests {
+			repo := &mockRepository{
+				GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+					return &domain.Enrollment{ID: id, Status: tt.from}, nil
+				},
+				UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
+					assert.Equal(t, tt.to, *status)
+					assert.Equal(t, string(tt.from), *currentStatus)
+					return nil
+				},
+				DeleteMock: func(ctx context.Context, id string, currentStatus string) error {
+					assert.Equal(t, "W", tt.to)
+					assert.Equal(t, string(tt.from), currentStatus)
+					return nil
+				},
+			}
+
+			service := enrollment.NewService(l, repo, nil, nil, 0)
+
+			status := tt.to
+			err := service.Update(context.Background(), "1", &status)
+
+			assert.Nil(t, err)
+		}
+	})
+
+	t.Run("should soft-delete the enrollment withdrawn through an update", func(t *testing.T) {
+		repo := enrollment.NewMemoryRepo(l)
+		service := enrollment.NewService(l, repo, nil, nil, 0)
+
+		ctx := context.Background()
+		require.NoError(t, repo.Create(ctx, &domain.Enrollment{ID: "1", UserID: "11", CourseID: "22", Status: domain.Active}))
+
+		status := "W"
+		require.NoError(t, service.Update(ctx, "1", &status))
+
+		_, err := service.Get(ctx, "1")
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "1"}, err)
+
+		withdrawn, err := service.GetAll(ctx, enrollment.Filters{CourseId: "22", IncludeDeleted: true}, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, withdrawn, 1)
+		assert.Equal(t, enrollment.Withdrawn, withdrawn[0].Status)
+	})
+}
+
+func TestService_Promotion(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("should promote the first waitlisted enrollment when a seat is released", func(t *testing.T) {
+		var updates [][2]string
+		repo := &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, CourseID: "22", Status: domain.Active}, nil
+			},
+			UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
+				updates = append(updates, [2]string{id, *status})
+				return nil
+			},
+			DeleteMock: func(ctx context.Context, id string, currentStatus string) error {
+				updates = append(updates, [2]string{id, "W"})
+				return nil
+			},
+			CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+				return 1, nil
+			},
+			GetWaitlistMock: func(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+				assert.Equal(t, "22", courseId)
+				assert.Equal(t, 1, limit)
+				return []domain.Enrollment{{ID: "2", CourseID: "22", Status: enrollment.Waitlisted}}, nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 2)
+
+		status := "W"
+		err := service.Update(context.Background(), "1", &status)
+
+		assert.Nil(t, err)
+		assert.Equal(t, [][2]string{{"1", "W"}, {"2", "P"}}, updates)
+	})
+
+	t.Run("should not promote when the course is still full", func(t *testing.T) {
+		counter := 0
+		repo := &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, CourseID: "22", Status: domain.Studying}, nil
+			},
+			UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
+				counter++
+				return nil
+			},
+			CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+				return 2, nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 2)
+
+		status := "C"
+		err := service.Update(context.Background(), "1", &status)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, counter)
+	})
+}
+
+func TestService_Waitlist(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("should return an error", func(t *testing.T) {
+		repo := &mockRepository{
+			GetWaitlistMock: func(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 2)
+
+		entries, err := service.Waitlist(context.Background(), "22")
+
+		assert.EqualError(t, err, "some error")
+		assert.Nil(t, entries)
+	})
+
+	t.Run("should return the waitlist with positions", func(t *testing.T) {
+		repo := &mockRepository{
+			GetWaitlistMock: func(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+				assert.Equal(t, 0, limit)
+				return []domain.Enrollment{{ID: "5"}, {ID: "3"}}, nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 2)
+
+		entries, err := service.Waitlist(context.Background(), "22")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []enrollment.WaitlistEntry{
+			{Position: 1, Enrollment: domain.Enrollment{ID: "5"}},
+			{Position: 2, Enrollment: domain.Enrollment{ID: "3"}},
+		}, entries)
+	})
+}
+
+func TestService_Delete(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("should return a not found error", func(t *testing.T) {
+		repo := &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return nil, enrollment.ErrNotFound{EnrollmentId: id}
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 0)
+
+		err := service.Delete(context.Background(), "1")
+
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentId: "1"}, err)
+	})
+
+	t.Run("should not withdraw a completed enrollment", func(t *testing.T) {
+		repo := &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: enrollment.Completed}, nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 0)
+
+		err := service.Delete(context.Background(), "1")
+
+		assert.Equal(t, enrollment.ErrInvalidTransition{From: "C", To: "W"}, err)
+	})
+
+	t.Run("should withdraw the enrollment and promote the waitlist", func(t *testing.T) {
+		deleted := 0
+		var promoted string
+		repo := &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, CourseID: "22", Status: domain.Pending}, nil
+			},
+			DeleteMock: func(ctx context.Context, id string, currentStatus string) error {
+				deleted++
+				assert.Equal(t, "1", id)
+				assert.Equal(t, "P", currentStatus)
+				return nil
+			},
+			CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+				return 0, nil
+			},
+			GetWaitlistMock: func(ctx context.Context, courseId string, limit int) ([]domain.Enrollment, error) {
+				return []domain.Enrollment{{ID: "2"}}, nil
+			},
+			UpdateMock: func(ctx context.Context, id string, status, currentStatus *string) error {
+				promoted = id
+				return nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, nil, nil, 1)
+
+		err := service.Delete(context.Background(), "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, deleted)
+		assert.Equal(t, "2", promoted)
+	})
 }
 
 func TestService_Count(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.DiscardHandler)
 
 	t.Run("should return an error", func(t *testing.T) {
 		expCount := 1
@@ -132,7 +448,7 @@ func TestService_Count(t *testing.T) {
 			},
 		}
 
-		service := enrollment.NewService(l, repo, nil, nil)
+		service := enrollment.NewService(l, repo, nil, nil, 0)
 
 		count, err := service.Count(context.Background(), enrollment.Filters{})
 
@@ -153,7 +469,7 @@ func TestService_Count(t *testing.T) {
 			},
 		}
 
-		service := enrollment.NewService(l, repo, nil, nil)
+		service := enrollment.NewService(l, repo, nil, nil, 0)
 		total, err := service.Count(context.Background(), enrollment.Filters{})
 
 		assert.Nil(t, err)
@@ -163,7 +479,7 @@ func TestService_Count(t *testing.T) {
 }
 
 func TestService_Create(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.DiscardHandler)
 	t.Run("should return an error in user sdk", func(t *testing.T) {
 		expectedErr := errors.New("some error")
 		expectedCounter := 1
@@ -176,9 +492,9 @@ func TestService_Create(t *testing.T) {
 			},
 		}
 
-		service := enrollment.NewService(l, nil, userSdk, nil)
+		service := enrollment.NewService(l, nil, userSdk, nil, 0)
 
-		enrollment, err := service.Create(context.Background(), "11", "22")
+		enrollment, _, err := service.Create(context.Background(), "11", "22")
 
 		assert.NotNil(t, err)
 		assert.Equal(t, expectedCounter, counter)
@@ -204,9 +520,9 @@ func TestService_Create(t *testing.T) {
 			},
 		}
 
-		service := enrollment.NewService(l, nil, userSdk, courseSdk)
+		service := enrollment.NewService(l, nil, userSdk, courseSdk, 0)
 
-		enrollment, err := service.Create(context.Background(), "11", "22")
+		enrollment, _, err := service.Create(context.Background(), "11", "22")
 
 		assert.NotNil(t, err)
 		assert.Equal(t, expectedCounter, counter)
@@ -233,15 +549,18 @@ func TestService_Create(t *testing.T) {
 		}
 
 		repo := &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
 			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
 				counter++
 				return errors.New("some error")
 			},
 		}
 
-		service := enrollment.NewService(l, repo, userSdk, courseSdk)
+		service := enrollment.NewService(l, repo, userSdk, courseSdk, 0)
 
-		enrollment, err := service.Create(context.Background(), "11", "22")
+		enrollment, _, err := service.Create(context.Background(), "11", "22")
 
 		assert.NotNil(t, err)
 		assert.Equal(t, expectedCounter, counter)
@@ -249,6 +568,154 @@ func TestService_Create(t *testing.T) {
 		assert.Nil(t, enrollment)
 	})
 
+	t.Run("should return an error if the user is already enrolled", func(t *testing.T) {
+		expectedErr := enrollment.ErrAlreadyEnrolled{EnrollmentId: "99", UserId: "11", CourseId: "22"}
+		userSdk := &userSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}
+
+		courseSdk := &courseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}
+
+		repo := &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				assert.Equal(t, enrollment.Filters{UserId: "11", CourseId: "22", IncludeDeleted: true}, filters)
+				return []domain.Enrollment{{ID: "99", UserID: "11", CourseID: "22"}}, nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, userSdk, courseSdk, 0)
+
+		enrollment, _, err := service.Create(context.Background(), "11", "22")
+
+		assert.Equal(t, expectedErr, err)
+		assert.Nil(t, enrollment)
+	})
+
+	t.Run("should waitlist the enrollment if the course is full", func(t *testing.T) {
+		userSdk := &userSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}
+
+		courseSdk := &courseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}
+
+		repo := &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
+			CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+				assert.Equal(t, "22", filter.CourseId)
+				assert.Equal(t, []string{"P", "A", "S"}, filter.Statuses)
+				return 2, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				enroll.ID = "123"
+				return nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, userSdk, courseSdk, 2)
+
+		enroll, position, err := service.Create(context.Background(), "11", "22")
+
+		assert.Nil(t, err)
+		assert.NotNil(t, enroll)
+		assert.Equal(t, enrollment.Waitlisted, enroll.Status)
+		assert.Equal(t, 1, position)
+	})
+
+	t.Run("should use the capacity of the course over the default one", func(t *testing.T) {
+		userSdk := &userSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}
+
+		courseSdk := &courseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}
+
+		seats := 1
+		repo := &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
+			GetCapacityMock: func(ctx context.Context, courseId string) (*int, error) {
+				assert.Equal(t, "22", courseId)
+				return &seats, nil
+			},
+			CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+				return 1, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				enroll.ID = "123"
+				return nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, userSdk, courseSdk, 30)
+
+		enroll, _, err := service.Create(context.Background(), "11", "22")
+
+		assert.Nil(t, err)
+		assert.Equal(t, enrollment.Waitlisted, enroll.Status)
+	})
+
+	t.Run("should create enrollment while seats are available", func(t *testing.T) {
+		expectedCounter := 3
+		counter := 0
+		userSdk := &userSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}
+
+		courseSdk := &courseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}
+
+		repo := &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
+			CountMock: func(ctx context.Context, filter enrollment.Filters) (int, error) {
+				counter++
+				return 1, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				counter++
+				return nil
+			},
+		}
+		repo.TransactionMock = func(ctx context.Context, fn func(repo enrollment.Repository) error) error {
+			counter++
+			return fn(repo)
+		}
+
+		service := enrollment.NewService(l, repo, userSdk, courseSdk, 2)
+
+		enrollment, _, err := service.Create(context.Background(), "11", "22")
+
+		assert.Nil(t, err)
+		assert.NotNil(t, enrollment)
+		assert.Equal(t, expectedCounter, counter)
+	})
+
 	t.Run("should create enrollment", func(t *testing.T) {
 		expectedCounter := 3
 		counter := 0
@@ -273,6 +740,9 @@ func TestService_Create(t *testing.T) {
 		}
 
 		repo := &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
 			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
 				counter++
 				enroll.ID = "123"
@@ -280,9 +750,9 @@ func TestService_Create(t *testing.T) {
 			},
 		}
 
-		service := enrollment.NewService(l, repo, userSdk, courseSdk)
+		service := enrollment.NewService(l, repo, userSdk, courseSdk, 0)
 
-		enrollment, err := service.Create(context.Background(), "11", "22")
+		enrollment, _, err := service.Create(context.Background(), "11", "22")
 
 		assert.Nil(t, err)
 		assert.Equal(t, expectedCounter, counter)
@@ -292,4 +762,172 @@ func TestService_Create(t *testing.T) {
 		assert.Equal(t, expectedStatus, enrollment.Status)
 		assert.Equal(t, expectedId, enrollment.ID)
 	})
+
+	t.Run("should enroll again a user who withdrew from the course", func(t *testing.T) {
+		userSdk := &userSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}
+
+		courseSdk := &courseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}
+
+		service := enrollment.NewService(l, enrollment.NewMemoryRepo(l), userSdk, courseSdk, 0)
+		ctx := context.Background()
+
+		enroll, _, err := service.Create(ctx, "11", "22")
+		require.NoError(t, err)
+		require.NoError(t, service.Delete(ctx, enroll.ID))
+
+		again, _, err := service.Create(ctx, "11", "22")
+		require.NoError(t, err)
+		assert.Equal(t, enroll.ID, again.ID)
+		assert.Equal(t, domain.Pending, again.Status)
+
+		_, _, err = service.Create(ctx, "11", "22")
+		assert.Equal(t, enrollment.ErrAlreadyEnrolled{EnrollmentId: enroll.ID, UserId: "11", CourseId: "22"}, err)
+	})
+}
+
+func TestService_CreateBulk(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+
+	userSdk := &userSdk.UserSdkMock{
+		GetMock: func(id string) (*domain.User, error) {
+			if id == "404" {
+				return nil, userSdkErr.ErrNotFound{Message: "user not found"}
+			}
+			return nil, nil
+		},
+	}
+
+	t.Run("should report the result of every item", func(t *testing.T) {
+		courseCalls := 0
+		courseSdk := &courseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				courseCalls++
+				return nil, nil
+			},
+		}
+
+		repo := &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				if filters.UserId == "2" {
+					return []domain.Enrollment{{ID: "99"}}, nil
+				}
+				return nil, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				enroll.ID = "id-" + enroll.UserID
+				return nil
+			},
+		}
+
+		service := enrollment.NewService(l, repo, userSdk, courseSdk, 0)
+
+		results, err := service.CreateBulk(context.Background(), []enrollment.BulkItem{
+			{UserId: "1", CourseId: "22"},
+			{UserId: "2", CourseId: "22"},
+			{UserId: "404", CourseId: "22"},
+			{UserId: "", CourseId: "22"},
+		}, false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, courseCalls)
+		assert.Len(t, results, 4)
+		assert.Equal(t, enrollment.BulkCreated, results[0].Result)
+		assert.Equal(t, "id-1", results[0].Enrollment.ID)
+		assert.Equal(t, enrollment.BulkDuplicate, results[1].Result)
+		assert.Equal(t, enrollment.BulkNotFound, results[2].Result)
+		assert.Equal(t, enrollment.BulkInvalid, results[3].Result)
+		assert.Equal(t, enrollment.ErrUserIdRequired.Error(), results[3].Err)
+	})
+
+	t.Run("should roll back the whole batch when an item fails in atomic mode", func(t *testing.T) {
+		courseSdk := &courseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}
+
+		transactions := 0
+		repo := &mockRepository{
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				return nil
+			},
+		}
+		repo.TransactionMock = func(ctx context.Context, fn func(repo enrollment.Repository) error) error {
+			transactions++
+			return fn(repo)
+		}
+
+		service := enrollment.NewService(l, repo, userSdk, courseSdk, 0)
+
+		results, err := service.CreateBulk(context.Background(), []enrollment.BulkItem{
+			{UserId: "1", CourseId: "22"},
+			{UserId: "404", CourseId: "22"},
+		}, true)
+
+		assert.Equal(t, enrollment.ErrBulkRolledBack, err)
+		assert.Equal(t, 1, transactions)
+		assert.Equal(t, enrollment.BulkRolledBack, results[0].Result)
+		assert.Nil(t, results[0].Enrollment)
+		assert.Equal(t, enrollment.BulkNotFound, results[1].Result)
+	})
+}
+
+func TestService_SetCapacity(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+	courseSdk := &courseSdk.CourseSdkMock{
+		GetMock: func(id string) (*domain.Course, error) {
+			if id != "c1" {
+				return nil, courseSDK.ErrNotFound{Message: "course not found"}
+			}
+			return nil, nil
+		},
+	}
+
+	t.Run("should reject seats lower than one", func(t *testing.T) {
+		service := enrollment.NewService(l, enrollment.NewMemoryRepo(l), nil, courseSdk, 0)
+
+		err := service.SetCapacity(context.Background(), "c1", 0)
+		assert.Equal(t, enrollment.ErrInvalidSeats, err)
+	})
+
+	t.Run("should return an error if the course does not exist", func(t *testing.T) {
+		service := enrollment.NewService(l, enrollment.NewMemoryRepo(l), nil, courseSdk, 0)
+
+		err := service.SetCapacity(context.Background(), "c2", 10)
+		assert.ErrorAs(t, err, &courseSDK.ErrNotFound{})
+	})
+
+	t.Run("should promote the waitlist into the new seats", func(t *testing.T) {
+		repo := enrollment.NewMemoryRepo(l)
+		service := enrollment.NewService(l, repo, nil, courseSdk, 1)
+
+		ctx := context.Background()
+		for i, status := range []domain.EnrollStatus{domain.Pending, enrollment.Waitlisted, enrollment.Waitlisted, enrollment.Waitlisted} {
+			created := time.Date(2024, 1, 1, 10, i, 0, 0, time.UTC)
+			id := fmt.Sprintf("e%d", i+1)
+			require.NoError(t, repo.Create(ctx, &domain.Enrollment{ID: id, UserID: id, CourseID: "c1", Status: status, CreatedAt: &created}))
+		}
+
+		require.NoError(t, service.SetCapacity(ctx, "c1", 3))
+
+		seats, err := repo.GetCapacity(ctx, "c1")
+		require.NoError(t, err)
+		assert.Equal(t, 3, *seats)
+
+		waitlist, err := service.Waitlist(ctx, "c1")
+		require.NoError(t, err)
+		require.Len(t, waitlist, 1)
+		assert.Equal(t, "e4", waitlist[0].ID)
+	})
 }
@@ -0,0 +1,47 @@
+package enrollment
+
+import "github.com/JuD4Mo/go_api_web_domain/domain"
+
+const (
+	Completed domain.EnrollStatus = "C"
+	Withdrawn domain.EnrollStatus = "W"
+	Rejected  domain.EnrollStatus = "R"
+	// Waitlisted enrollments wait for a seat of a full course, they are
+	// promoted to Pending by the service when a seat is released.
+	Waitlisted domain.EnrollStatus = "WL"
+)
+
+// transitions lists, for every status, the statuses an enrollment can move to.
+// Completed, Withdrawn and Rejected are terminal and have no way out.
+var transitions = map[domain.EnrollStatus][]domain.EnrollStatus{
+	domain.Pending:  {domain.Active, Rejected, Withdrawn},
+	domain.Active:   {domain.Studying, Withdrawn},
+	domain.Studying: {Completed, Withdrawn},
+	Completed:       {},
+	Withdrawn:       {},
+	Rejected:        {},
+	Waitlisted:      {Withdrawn},
+}
+
+func validStatus(status domain.EnrollStatus) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+func holdsSeat(status domain.EnrollStatus) bool {
+	for _, s := range seatStatuses {
+		if s == string(status) {
+			return true
+		}
+	}
+	return false
+}
+
+func canTransition(from, to domain.EnrollStatus) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package enrollment
+
+import (
+	"context"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+
+// startSpan starts a span with the global tracer provider, it is looked up on
+// every call so the provider can be set after the service is built.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// getUser calls the user service in a client span, the SDK transports do not
+// take a context so the call can not be traced any deeper and the request ID
+// is not sent to the service.
+func (s service) getUser(ctx context.Context, id string) (*domain.User, error) {
+	_, span := startSpan(ctx, "user.Get", attribute.String("user.id", id))
+	user, err := s.userTransport.Get(id)
+	endSpan(span, err)
+	return user, err
+}
+
+// getCourse calls the course service in a client span.
+func (s service) getCourse(ctx context.Context, id string) (*domain.Course, error) {
+	_, span := startSpan(ctx, "course.Get", attribute.String("course.id", id))
+	course, err := s.courseTransport.Get(id)
+	endSpan(span, err)
+	return course, err
+}
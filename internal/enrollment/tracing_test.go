@@ -0,0 +1,56 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	courseSdkMock "github.com/JuD4Mo/go_api_web_sdk/course/mock"
+	userSdkMock "github.com/JuD4Mo/go_api_web_sdk/user/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestService_Create_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	courseErr := errors.New("course service unavailable")
+	svc := enrollment.NewService(slog.New(slog.DiscardHandler), enrollment.NewMemoryRepo(slog.New(slog.DiscardHandler)),
+		&userSdkMock.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return &domain.User{ID: id}, nil
+			},
+		},
+		&courseSdkMock.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, courseErr
+			},
+		}, 0)
+
+	_, _, err := svc.Create(context.Background(), "u1", "c1")
+	assert.Equal(t, courseErr, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	user, course, create := spans[0], spans[1], spans[2]
+	assert.Equal(t, "user.Get", user.Name())
+	assert.Equal(t, codes.Unset, user.Status().Code)
+	assert.Equal(t, "course.Get", course.Name())
+	assert.Equal(t, codes.Error, course.Status().Code)
+	assert.Equal(t, "enrollment.Create", create.Name())
+	assert.Equal(t, codes.Error, create.Status().Code)
+
+	assert.Equal(t, create.SpanContext().SpanID(), user.Parent().SpanID())
+	assert.Equal(t, create.SpanContext().SpanID(), course.Parent().SpanID())
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+
+	courseSDK "github.com/JuD4Mo/go_api_web_sdk/course"
+	userSDK "github.com/JuD4Mo/go_api_web_sdk/user"
+	"gorm.io/gorm"
+)
+
+// probeId is looked up in the user and course services, a not found answer
+// means the service is reachable.
+const probeId = "00000000-0000-0000-0000-000000000000"
+
+// DatabaseCheck pings the database of db.
+func DatabaseCheck(db *gorm.DB) Check {
+	return Check{
+		Name: "database",
+		Run: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+	}
+}
+
+// UserServiceCheck calls the user service through its SDK transport.
+func UserServiceCheck(transport userSDK.Transport) Check {
+	return Check{
+		Name: "user_service",
+		Run: func(_ context.Context) error {
+			_, err := transport.Get(probeId)
+			if errors.As(err, &userSDK.ErrNotFound{}) {
+				return nil
+			}
+			return err
+		},
+	}
+}
+
+// CourseServiceCheck calls the course service through its SDK transport.
+func CourseServiceCheck(transport courseSDK.Transport) Check {
+	return Check{
+		Name: "course_service",
+		Run: func(_ context.Context) error {
+			_, err := transport.Get(probeId)
+			if errors.As(err, &courseSDK.ErrNotFound{}) {
+				return nil
+			}
+			return err
+		},
+	}
+}
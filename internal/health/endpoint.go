@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/JuD4Mo/go_lib_response/response"
+)
+
+type (
+	Controller func(ctx context.Context, request interface{}) (response interface{}, err error)
+
+	Endpoints struct {
+		Live  Controller
+		Ready Controller
+	}
+
+	// UnavailableResponse is the 503 returned while a dependency is down, Data
+	// holds the report of every check.
+	UnavailableResponse struct {
+		Status  int         `json:"status"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data,omitempty"`
+	}
+)
+
+func MakeEndpoints(s Service) Endpoints {
+	return Endpoints{
+		Live:  makeLiveEndpoint(s),
+		Ready: makeReadyEndpoint(s),
+	}
+}
+
+func makeLiveEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return response.OK("alive", s.Live(ctx), nil), nil
+	}
+}
+
+func makeReadyEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		report := s.Ready(ctx)
+		if report.Status != StatusUp {
+			return nil, unavailable("not ready", report)
+		}
+
+		return response.OK("ready", report, nil), nil
+	}
+}
+
+func unavailable(msg string, data interface{}) response.Response {
+	return &UnavailableResponse{
+		Status:  http.StatusServiceUnavailable,
+		Message: msg,
+		Data:    data,
+	}
+}
+
+func (u *UnavailableResponse) Error() string {
+	return u.Message
+}
+
+func (u *UnavailableResponse) StatusCode() int {
+	return u.Status
+}
+
+func (u *UnavailableResponse) GetBody() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+func (u *UnavailableResponse) GetData() interface{} {
+	return u.Data
+}
@@ -0,0 +1,45 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/health"
+	"github.com/JuD4Mo/go_lib_response/response"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpoints(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("live", func(t *testing.T) {
+		endpoints := health.MakeEndpoints(health.NewService(l, time.Second, check("database", errors.New("down"))))
+
+		resp, err := endpoints.Live(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		endpoints := health.MakeEndpoints(health.NewService(l, time.Second, check("database", nil)))
+
+		resp, err := endpoints.Ready(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.(response.Response).StatusCode())
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		endpoints := health.MakeEndpoints(health.NewService(l, time.Second, check("database", errors.New("down"))))
+
+		resp, err := endpoints.Ready(ctx, nil)
+		assert.Nil(t, resp)
+		assert.Equal(t, http.StatusServiceUnavailable, err.(response.Response).StatusCode())
+
+		report := err.(response.Response).GetData().(health.Report)
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, "down", report.Checks["database"].Error)
+	})
+}
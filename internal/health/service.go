@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+type (
+	// Check is a dependency of the service. Run returns nil when the
+	// dependency can be used.
+	Check struct {
+		Name string
+		Run  func(ctx context.Context) error
+	}
+
+	Service interface {
+		// Live reports that the process is up, it does not check the
+		// dependencies.
+		Live(ctx context.Context) Report
+		// Ready runs every check concurrently, the service is ready when all
+		// of them pass within the timeout.
+		Ready(ctx context.Context) Report
+	}
+
+	Report struct {
+		Status string                 `json:"status"`
+		Checks map[string]CheckResult `json:"checks,omitempty"`
+	}
+
+	CheckResult struct {
+		Status    string  `json:"status"`
+		LatencyMs float64 `json:"latency_ms"`
+		Error     string  `json:"error,omitempty"`
+	}
+
+	service struct {
+		log     *slog.Logger
+		timeout time.Duration
+		checks  []Check
+	}
+)
+
+// NewService builds the health service. timeout bounds every readiness check.
+func NewService(log *slog.Logger, timeout time.Duration, checks ...Check) Service {
+	return &service{
+		log:     log,
+		timeout: timeout,
+		checks:  checks,
+	}
+}
+
+func (s service) Live(_ context.Context) Report {
+	return Report{Status: StatusUp}
+}
+
+func (s service) Ready(ctx context.Context) Report {
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(s.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range s.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			result := s.run(ctx, check)
+			if result.Status != StatusUp {
+				s.log.WarnContext(ctx, "health check failed", "check", check.Name, "error", result.Error)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run executes the check, giving up when it takes longer than the timeout
+// even if the check does not honor its context.
+func (s service) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
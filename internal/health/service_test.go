@@ -0,0 +1,120 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/health"
+	courseSdk "github.com/JuD4Mo/go_api_web_sdk/course"
+	courseSdkMock "github.com/JuD4Mo/go_api_web_sdk/course/mock"
+	userSdk "github.com/JuD4Mo/go_api_web_sdk/user"
+	userSdkMock "github.com/JuD4Mo/go_api_web_sdk/user/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+var l = slog.New(slog.DiscardHandler)
+
+func check(name string, err error) health.Check {
+	return health.Check{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			return err
+		},
+	}
+}
+
+func TestService_Live(t *testing.T) {
+	svc := health.NewService(l, time.Second, check("database", errors.New("down")))
+
+	assert.Equal(t, health.Report{Status: health.StatusUp}, svc.Live(context.Background()))
+}
+
+func TestService_Ready(t *testing.T) {
+	t.Run("should be up when every check passes", func(t *testing.T) {
+		svc := health.NewService(l, time.Second, check("database", nil), check("user_service", nil))
+
+		report := svc.Ready(context.Background())
+		assert.Equal(t, health.StatusUp, report.Status)
+		assert.Len(t, report.Checks, 2)
+		assert.Equal(t, health.StatusUp, report.Checks["database"].Status)
+		assert.Equal(t, health.StatusUp, report.Checks["user_service"].Status)
+	})
+
+	t.Run("should be up without checks", func(t *testing.T) {
+		report := health.NewService(l, time.Second).Ready(context.Background())
+		assert.Equal(t, health.StatusUp, report.Status)
+		assert.Empty(t, report.Checks)
+	})
+
+	t.Run("should be down when a check fails", func(t *testing.T) {
+		svc := health.NewService(l, time.Second, check("database", errors.New("connection refused")), check("user_service", nil))
+
+		report := svc.Ready(context.Background())
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, health.CheckResult{Status: health.StatusDown, Error: "connection refused"}, withoutLatency(report.Checks["database"]))
+		assert.Equal(t, health.StatusUp, report.Checks["user_service"].Status)
+	})
+
+	t.Run("should give up on checks slower than the timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		slow := health.Check{
+			Name: "course_service",
+			// ignores its context like the SDK transports
+			Run: func(ctx context.Context) error {
+				<-release
+				return nil
+			},
+		}
+		svc := health.NewService(l, 20*time.Millisecond, slow)
+
+		report := svc.Ready(context.Background())
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["course_service"].Error)
+		assert.GreaterOrEqual(t, report.Checks["course_service"].LatencyMs, float64(20))
+	})
+}
+
+func TestServiceChecks(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		userErr error
+		wantErr error
+	}{
+		{name: "found", userErr: nil, wantErr: nil},
+		{name: "not found means reachable", userErr: userSdk.ErrNotFound{Message: "user not found"}, wantErr: nil},
+		{name: "other errors", userErr: errors.New("timeout"), wantErr: errors.New("timeout")},
+	}
+
+	for _, tt := range tests {
+		t.Run("user service "+tt.name, func(t *testing.T) {
+			transport := &userSdkMock.UserSdkMock{
+				GetMock: func(id string) (*domain.User, error) {
+					return nil, tt.userErr
+				},
+			}
+			assert.Equal(t, tt.wantErr, health.UserServiceCheck(transport).Run(ctx))
+		})
+	}
+
+	t.Run("course service not found means reachable", func(t *testing.T) {
+		transport := &courseSdkMock.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, courseSdk.ErrNotFound{Message: "course not found"}
+			},
+		}
+		assert.NoError(t, health.CourseServiceCheck(transport).Run(ctx))
+	})
+}
+
+func withoutLatency(result health.CheckResult) health.CheckResult {
+	result.LatencyMs = 0
+	return result
+}
@@ -0,0 +1,6 @@
+package idempotency
+
+import "errors"
+
+var ErrKeyReused = errors.New("idempotency key was already used with a different request")
+var ErrInProgress = errors.New("a request with the same idempotency key is still in progress")
@@ -0,0 +1,102 @@
+package idempotency
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type (
+	// memoryRepo is a Repository kept in memory, meant for local development
+	// with ENROLLMENT_REPOSITORY=memory. It mirrors the reservation, takeover
+	// and expiry semantics of the GORM repository.
+	memoryRepo struct {
+		mu      sync.Mutex
+		records map[memoryKey]Record
+		log     *slog.Logger
+		lease   time.Duration
+		ttl     time.Duration
+	}
+
+	memoryKey struct {
+		subject, key string
+	}
+)
+
+// NewMemoryRepo builds the repository of the idempotency keys kept in memory,
+// with the lease and ttl of NewRepo.
+func NewMemoryRepo(log *slog.Logger, lease, ttl time.Duration) Repository {
+	return &memoryRepo{
+		records: make(map[memoryKey]Record),
+		log:     log,
+		lease:   lease,
+		ttl:     ttl,
+	}
+}
+
+func (repo *memoryRepo) Reserve(ctx context.Context, subject, key, requestHash string) (*Record, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	id := memoryKey{subject: subject, key: key}
+	record, ok := repo.records[id]
+
+	abandoned := ok && (record.ExpiresAt.Before(now) || (record.StatusCode == 0 && record.CreatedAt.Before(now.Add(-repo.lease))))
+	if ok && !abandoned {
+		record.Body = append([]byte(nil), record.Body...)
+		return &record, nil
+	}
+
+	if abandoned {
+		repo.log.InfoContext(ctx, "took over expired or abandoned idempotency key", "idempotency_key", key)
+	}
+
+	repo.records[id] = Record{
+		Subject:     subject,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(repo.ttl),
+	}
+	return nil, nil
+}
+
+func (repo *memoryRepo) Complete(ctx context.Context, subject, key string, statusCode int, body []byte) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	id := memoryKey{subject: subject, key: key}
+	record, ok := repo.records[id]
+	if !ok {
+		return nil
+	}
+
+	record.StatusCode, record.Body = statusCode, append([]byte(nil), body...)
+	repo.records[id] = record
+	return nil
+}
+
+func (repo *memoryRepo) Release(ctx context.Context, subject, key string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.records, memoryKey{subject: subject, key: key})
+	return nil
+}
+
+func (repo *memoryRepo) DeleteExpired(ctx context.Context) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for id, record := range repo.records {
+		if record.ExpiresAt.Before(now) {
+			delete(repo.records, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,113 @@
+package idempotency_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	l := slog.New(slog.DiscardHandler)
+
+	t.Run("returns the stored record of a reserved key", func(t *testing.T) {
+		repo := idempotency.NewMemoryRepo(l, lease, ttl)
+
+		record, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+
+		record, err = repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, 0, record.StatusCode)
+
+		require.NoError(t, repo.Complete(ctx, "u1", "k1", 201, []byte(`{"status":201}`)))
+		record, err = repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		assert.Equal(t, 201, record.StatusCode)
+		assert.Equal(t, `{"status":201}`, string(record.Body))
+	})
+
+	t.Run("scopes the keys to the subject", func(t *testing.T) {
+		repo := idempotency.NewMemoryRepo(l, lease, ttl)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+
+		record, err := repo.Reserve(ctx, "u2", "k1", "h2")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("takes over a reservation abandoned after the lease", func(t *testing.T) {
+		repo := idempotency.NewMemoryRepo(l, 10*time.Millisecond, ttl)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		time.Sleep(20 * time.Millisecond)
+
+		record, err := repo.Reserve(ctx, "u1", "k1", "h2")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+
+		record, err = repo.Reserve(ctx, "u1", "k1", "h2")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, "h2", record.RequestHash)
+	})
+
+	t.Run("keeps a completed response until it expires", func(t *testing.T) {
+		repo := idempotency.NewMemoryRepo(l, 10*time.Millisecond, 50*time.Millisecond)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NoError(t, repo.Complete(ctx, "u1", "k1", 201, []byte(`{}`)))
+
+		time.Sleep(20 * time.Millisecond)
+		record, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, 201, record.StatusCode)
+
+		time.Sleep(50 * time.Millisecond)
+		record, err = repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("deletes the expired records", func(t *testing.T) {
+		repo := idempotency.NewMemoryRepo(l, lease, 20*time.Millisecond)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		time.Sleep(30 * time.Millisecond)
+		_, err = repo.Reserve(ctx, "u1", "k2", "h1")
+		require.NoError(t, err)
+
+		deleted, err := repo.DeleteExpired(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		record, err := repo.Reserve(ctx, "u1", "k2", "h1")
+		require.NoError(t, err)
+		assert.NotNil(t, record)
+	})
+
+	t.Run("releases the key", func(t *testing.T) {
+		repo := idempotency.NewMemoryRepo(l, lease, ttl)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NoError(t, repo.Release(ctx, "u1", "k1"))
+
+		record, err := repo.Reserve(ctx, "u1", "k1", "h2")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+}
@@ -0,0 +1,168 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"gorm.io/gorm"
+)
+
+type (
+	// Record is a request made with an Idempotency-Key by a subject, the keys
+	// of different callers never collide. StatusCode and Body stay empty while
+	// the first request holding the key is still being processed.
+	Record struct {
+		Subject     string `gorm:"type:varchar(255);primaryKey"`
+		Key         string `gorm:"column:idempotency_key;type:varchar(255);primaryKey"`
+		RequestHash string `gorm:"type:char(64);not null"`
+		StatusCode  int
+		Body        []byte
+		CreatedAt   time.Time
+		ExpiresAt   time.Time `gorm:"index:idx_idempotency_keys_expires_at"`
+	}
+
+	Repository interface {
+		// Reserve stores the key of the subject for the request. When the key
+		// already exists the stored record is returned instead, unless it
+		// expired or its request is still in progress after the lease, then
+		// the key is reserved again.
+		Reserve(ctx context.Context, subject, key, requestHash string) (*Record, error)
+		Complete(ctx context.Context, subject, key string, statusCode int, body []byte) error
+		Release(ctx context.Context, subject, key string) error
+		// DeleteExpired deletes the records past their TTL and returns how
+		// many were deleted.
+		DeleteExpired(ctx context.Context) (int, error)
+	}
+
+	repo struct {
+		db  *gorm.DB
+		log *slog.Logger
+		// lease is how long a reservation waits for its response before it
+		// is taken as abandoned
+		lease time.Duration
+		// ttl is how long a record is kept
+		ttl time.Duration
+	}
+)
+
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
+
+// NewRepo builds the repository of the idempotency keys. A reservation without
+// response after lease, left by a request that crashed or timed out, can be
+// taken over and the records are kept for ttl.
+func NewRepo(db *gorm.DB, log *slog.Logger, lease, ttl time.Duration) Repository {
+	return &repo{
+		db:    db,
+		log:   log,
+		lease: lease,
+		ttl:   ttl,
+	}
+}
+
+func (repo *repo) Reserve(ctx context.Context, subject, key, requestHash string) (*Record, error) {
+	now := time.Now()
+	result := repo.db.WithContext(ctx).Create(&Record{
+		Subject:     subject,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(repo.ttl),
+	})
+	if result.Error == nil {
+		return nil, nil
+	}
+
+	if !errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		repo.log.ErrorContext(ctx, "reserving idempotency key", "idempotency_key", key, logging.Err(result.Error))
+		return nil, result.Error
+	}
+
+	// the condition is checked by the update itself, only one of the
+	// requests racing for an abandoned key takes it over
+	result = repo.db.WithContext(ctx).Model(&Record{}).
+		Where("subject = ? AND idempotency_key = ?", subject, key).
+		Where("expires_at < ? OR (status_code = 0 AND created_at < ?)", now, now.Add(-repo.lease)).
+		Updates(map[string]interface{}{
+			"request_hash": requestHash,
+			"status_code":  0,
+			"body":         nil,
+			"created_at":   now,
+			"expires_at":   now.Add(repo.ttl),
+		})
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "taking over idempotency key", "idempotency_key", key, logging.Err(result.Error))
+		return nil, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		repo.log.InfoContext(ctx, "took over expired or abandoned idempotency key", "idempotency_key", key)
+		return nil, nil
+	}
+
+	var record Record
+	result = repo.db.WithContext(ctx).Where("subject = ? AND idempotency_key = ?", subject, key).First(&record)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "getting idempotency key", "idempotency_key", key, logging.Err(result.Error))
+		return nil, result.Error
+	}
+
+	return &record, nil
+}
+
+func (repo *repo) Complete(ctx context.Context, subject, key string, statusCode int, body []byte) error {
+	values := map[string]interface{}{
+		"status_code": statusCode,
+		"body":        body,
+	}
+
+	result := repo.db.WithContext(ctx).Model(&Record{}).Where("subject = ? AND idempotency_key = ?", subject, key).Updates(values)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "completing idempotency key", "idempotency_key", key, logging.Err(result.Error))
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *repo) Release(ctx context.Context, subject, key string) error {
+	result := repo.db.WithContext(ctx).Where("subject = ? AND idempotency_key = ?", subject, key).Delete(&Record{})
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "releasing idempotency key", "idempotency_key", key, logging.Err(result.Error))
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *repo) DeleteExpired(ctx context.Context) (int, error) {
+	result := repo.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&Record{})
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "deleting expired idempotency keys", logging.Err(result.Error))
+		return 0, result.Error
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// Cleanup deletes the expired records every interval until ctx is done.
+func Cleanup(ctx context.Context, log *slog.Logger, repo Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if deleted, err := repo.DeleteExpired(ctx); err == nil && deleted > 0 {
+			log.InfoContext(ctx, "deleted expired idempotency keys", "count", deleted)
+		}
+	}
+}
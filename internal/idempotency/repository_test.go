@@ -0,0 +1,169 @@
+package idempotency_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/idempotency"
+	"github.com/JuD4Mo/go_api_web_enrollment/pkg/bootstrap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	lease = time.Minute
+	ttl   = time.Hour
+)
+
+// newRepo builds the repository on an in-memory sqlite database, or on the
+// mysql or postgres database of REPOSITORY_TEST_DRIVER configured with the
+// DATABASE_* envs, whose keys are deleted first.
+func newRepo(t *testing.T) (idempotency.Repository, *gorm.DB) {
+	l := slog.New(slog.DiscardHandler)
+	driver := os.Getenv("REPOSITORY_TEST_DRIVER")
+
+	dialector := sqlite.Open(":memory:")
+	if driver != "" {
+		var err error
+		dialector, err = bootstrap.Dialector(driver)
+		require.NoError(t, err)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Discard,
+	})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	if driver == "" {
+		// every connection to :memory: is a new database
+		sqlDB.SetMaxOpenConns(1)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	require.NoError(t, bootstrap.Migrate(db, l))
+	if driver != "" {
+		require.NoError(t, db.Exec("DELETE FROM idempotency_keys").Error)
+	}
+	return idempotency.NewRepo(db, l, lease, ttl), db
+}
+
+// age moves the record of the key back in time.
+func age(t *testing.T, db *gorm.DB, key string, d time.Duration) {
+	var record idempotency.Record
+	require.NoError(t, db.Where("idempotency_key = ?", key).First(&record).Error)
+	require.NoError(t, db.Model(&idempotency.Record{}).Where("idempotency_key = ?", key).Updates(map[string]interface{}{
+		"created_at": record.CreatedAt.Add(-d),
+		"expires_at": record.ExpiresAt.Add(-d),
+	}).Error)
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns the stored record of a reserved key", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		record, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+
+		record, err = repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, 0, record.StatusCode)
+
+		require.NoError(t, repo.Complete(ctx, "u1", "k1", 201, []byte(`{"status":201}`)))
+		record, err = repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		assert.Equal(t, 201, record.StatusCode)
+		assert.Equal(t, `{"status":201}`, string(record.Body))
+	})
+
+	t.Run("scopes the keys to the subject", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NoError(t, repo.Complete(ctx, "u1", "k1", 201, []byte(`{}`)))
+
+		record, err := repo.Reserve(ctx, "u2", "k1", "h2")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("takes over a reservation abandoned after the lease", func(t *testing.T) {
+		repo, db := newRepo(t)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		age(t, db, "k1", lease+time.Second)
+
+		record, err := repo.Reserve(ctx, "u1", "k1", "h2")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+
+		// the new reservation holds the key for a whole lease again
+		record, err = repo.Reserve(ctx, "u1", "k1", "h2")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, "h2", record.RequestHash)
+	})
+
+	t.Run("keeps a completed response until it expires", func(t *testing.T) {
+		repo, db := newRepo(t)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NoError(t, repo.Complete(ctx, "u1", "k1", 201, []byte(`{}`)))
+
+		age(t, db, "k1", lease+time.Second)
+		record, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, 201, record.StatusCode)
+
+		age(t, db, "k1", ttl)
+		record, err = repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("deletes the expired records", func(t *testing.T) {
+		repo, db := newRepo(t)
+
+		for _, key := range []string{"k1", "k2"} {
+			_, err := repo.Reserve(ctx, "u1", key, "h1")
+			require.NoError(t, err)
+		}
+		age(t, db, "k1", ttl+time.Second)
+
+		deleted, err := repo.DeleteExpired(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		var count int64
+		require.NoError(t, db.Model(&idempotency.Record{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("releases the key", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		_, err := repo.Reserve(ctx, "u1", "k1", "h1")
+		require.NoError(t, err)
+		require.NoError(t, repo.Release(ctx, "u1", "k1"))
+
+		record, err := repo.Reserve(ctx, "u1", "k1", "h2")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+}
@@ -0,0 +1,37 @@
+package idempotency
+
+import "encoding/json"
+
+// Response replays the status code and body stored for a key.
+type Response struct {
+	Status int
+	Body   []byte
+}
+
+func (r *Response) StatusCode() int {
+	return r.Status
+}
+
+func (r *Response) GetBody() ([]byte, error) {
+	return r.Body, nil
+}
+
+func (r *Response) Error() string {
+	if r.Status < 400 {
+		return ""
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(r.Body, &body)
+	return body.Message
+}
+
+func (r *Response) GetData() interface{} {
+	return nil
+}
+
+func (r *Response) MarshalJSON() ([]byte, error) {
+	return r.Body, nil
+}
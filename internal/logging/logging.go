@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Keys of the request-scoped fields added to the log lines.
+const (
+	RequestIDKey    = "request_id"
+	RouteKey        = "route"
+	EnrollmentIDKey = "enrollment_id"
+	UserIDKey       = "user_id"
+	CourseIDKey     = "course_id"
+	SubjectKey      = "subject"
+	ErrorKey        = "error"
+)
+
+type (
+	// Handler adds the fields stored in the context with With to the records
+	// of the wrapped handler.
+	Handler struct {
+		slog.Handler
+	}
+
+	fieldsKey struct{}
+)
+
+// New builds a logger writing to w. format is "json" (the default) or "text"
+// and level one of "debug", "info" (the default), "warn" or "error".
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{AddSource: true, Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	return slog.New(Handler{h}), nil
+}
+
+// With returns a copy of ctx carrying the key-value pairs of args, which are
+// added to every line logged with the context.
+func With(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+
+	var r slog.Record
+	r.Add(args...)
+
+	fields := append([]slog.Attr{}, fields(ctx)...)
+	r.Attrs(func(attr slog.Attr) bool {
+		fields = append(fields, attr)
+		return true
+	})
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Err returns the attribute used to log an error.
+func Err(err error) slog.Attr {
+	return slog.Any(ErrorKey, err)
+}
+
+func fields(ctx context.Context) []slog.Attr {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return fields
+}
+
+// Handle adds the context fields to the record, the attributes of the record
+// win over the context fields with the same key, as well as the fields added
+// last to the context.
+func (h Handler) Handle(ctx context.Context, r slog.Record) error {
+	ctxFields := fields(ctx)
+	if len(ctxFields) == 0 {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	seen := make(map[string]bool, r.NumAttrs()+len(ctxFields))
+	r.Attrs(func(attr slog.Attr) bool {
+		seen[attr.Key] = true
+		return true
+	})
+
+	keep := make([]bool, len(ctxFields))
+	for i := len(ctxFields) - 1; i >= 0; i-- {
+		if key := ctxFields[i].Key; !seen[key] {
+			seen[key] = true
+			keep[i] = true
+		}
+	}
+
+	r = r.Clone()
+	for i, field := range ctxFields {
+		if keep[i] {
+			r.AddAttrs(field)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return Handler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h Handler) WithGroup(name string) slog.Handler {
+	return Handler{h.Handler.WithGroup(name)}
+}
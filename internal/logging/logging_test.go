@@ -0,0 +1,84 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("invalid format", func(t *testing.T) {
+		_, err := logging.New(&bytes.Buffer{}, "xml", "")
+		assert.EqualError(t, err, `invalid log format "xml"`)
+	})
+
+	t.Run("invalid level", func(t *testing.T) {
+		_, err := logging.New(&bytes.Buffer{}, "", "verbose")
+		assert.EqualError(t, err, `invalid log level "verbose"`)
+	})
+
+	t.Run("level", func(t *testing.T) {
+		var buf bytes.Buffer
+		log, err := logging.New(&buf, "json", "warn")
+		require.NoError(t, err)
+
+		log.Info("skipped")
+		log.Warn("written")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], `"msg":"written"`)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		log, err := logging.New(&buf, "text", "")
+		require.NoError(t, err)
+
+		log.Info("written", "key", "value")
+		assert.Contains(t, buf.String(), "level=INFO")
+		assert.Contains(t, buf.String(), "msg=written key=value")
+	})
+}
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logging.New(&buf, "json", "debug")
+	require.NoError(t, err)
+
+	ctx := logging.With(context.Background(), logging.RequestIDKey, "r1", logging.EnrollmentIDKey, "e1")
+	ctx = logging.With(ctx, logging.UserIDKey, "u1", logging.EnrollmentIDKey, "e2")
+
+	// the context fields are not shared with the parent context
+	logging.With(ctx, logging.CourseIDKey, "c1")
+
+	log.InfoContext(ctx, "from context")
+	log.InfoContext(ctx, "explicit", logging.UserIDKey, "u2", logging.Err(errors.New("failed")))
+	log.Info("no context")
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &fields))
+		lines = append(lines, fields)
+	}
+	require.Len(t, lines, 3)
+
+	assert.Equal(t, "r1", lines[0][logging.RequestIDKey])
+	assert.Equal(t, "e2", lines[0][logging.EnrollmentIDKey])
+	assert.Equal(t, "u1", lines[0][logging.UserIDKey])
+	assert.NotContains(t, lines[0], logging.CourseIDKey)
+
+	assert.Equal(t, "u2", lines[1][logging.UserIDKey])
+	assert.Equal(t, "e2", lines[1][logging.EnrollmentIDKey])
+	assert.Equal(t, "failed", lines[1][logging.ErrorKey])
+
+	assert.NotContains(t, lines[2], logging.RequestIDKey)
+}
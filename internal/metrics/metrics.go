@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "enrollment"
+
+// Metrics are the metrics of the service. They are go-kit metrics backed by
+// Prometheus, the instrumented code only depends on the go-kit interfaces.
+type Metrics struct {
+	// HTTPRequests and HTTPLatency are labeled by route, method and code.
+	HTTPRequests metrics.Counter
+	HTTPLatency  metrics.Histogram
+
+	// DependencyRequests and DependencyLatency are labeled by dependency,
+	// operation and result.
+	DependencyRequests metrics.Counter
+	DependencyLatency  metrics.Histogram
+
+	// RepositoryOperations and RepositoryLatency are labeled by operation and
+	// result.
+	RepositoryOperations metrics.Counter
+	RepositoryLatency    metrics.Histogram
+
+	// Enrollments is labeled by status.
+	Enrollments metrics.Gauge
+}
+
+// New creates the metrics and registers them in reg.
+func New(reg prometheus.Registerer) *Metrics {
+	httpRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests handled.",
+	}, []string{"route", "method", "code"})
+
+	httpLatency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to handle the HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	dependencyRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dependency",
+		Name:      "requests_total",
+		Help:      "Number of calls made to the user and course services.",
+	}, []string{"dependency", "operation", "result"})
+
+	dependencyLatency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "dependency",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken by the calls to the user and course services.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"dependency", "operation", "result"})
+
+	repositoryOperations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "operations_total",
+		Help:      "Number of enrollment repository operations.",
+	}, []string{"operation", "result"})
+
+	repositoryLatency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "operation_duration_seconds",
+		Help:      "Time taken by the enrollment repository operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	enrollments := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "enrollments",
+		Help:      "Number of enrollments by status.",
+	}, []string{"status"})
+
+	reg.MustRegister(httpRequests, httpLatency, dependencyRequests, dependencyLatency,
+		repositoryOperations, repositoryLatency, enrollments)
+
+	return &Metrics{
+		HTTPRequests:         kitprometheus.NewCounter(httpRequests),
+		HTTPLatency:          kitprometheus.NewHistogram(httpLatency),
+		DependencyRequests:   kitprometheus.NewCounter(dependencyRequests),
+		DependencyLatency:    kitprometheus.NewHistogram(dependencyLatency),
+		RepositoryOperations: kitprometheus.NewCounter(repositoryOperations),
+		RepositoryLatency:    kitprometheus.NewHistogram(repositoryLatency),
+		Enrollments:          kitprometheus.NewGauge(enrollments),
+	}
+}
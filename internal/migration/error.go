@@ -0,0 +1,31 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoMigrationApplied = errors.New("there is no applied migration to revert")
+var ErrLockNotAcquired = errors.New("schema_migrations lock was not acquired")
+
+type ErrUnknownVersion struct {
+	Version int64
+}
+
+type ErrMigrationFailed struct {
+	Version int64
+	Name    string
+	Err     error
+}
+
+func (e ErrUnknownVersion) Error() string {
+	return fmt.Sprintf("migration %d is applied but unknown to this build", e.Version)
+}
+
+func (e ErrMigrationFailed) Error() string {
+	return fmt.Sprintf("migration %d %s failed: %s", e.Version, e.Name, e.Err)
+}
+
+func (e ErrMigrationFailed) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+)
+
+// lockName identifies the lock held while the migrations are applied or
+// reverted, by name on MySQL and by its hash on Postgres.
+const lockName = "go_api_web_enrollment.schema_migrations"
+
+// lock keeps the replicas started at the same time from applying the same
+// migration twice, they wait for each other and the next one finds the
+// migrations applied. The lock belongs to a connection of its own until
+// unlock is called. SQLite databases are used by a single process and are
+// not locked.
+func (m *migrator) lock(ctx context.Context) (unlock func(), err error) {
+	var release string
+	switch m.db.Dialector.Name() {
+	case "postgres":
+		release = "SELECT pg_advisory_unlock(hashtext($1))"
+	case "mysql":
+		release = "SELECT RELEASE_LOCK(?)"
+	default:
+		return func() {}, nil
+	}
+
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.db.Dialector.Name() == "postgres" {
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", lockName)
+	} else {
+		// a negative timeout waits until the lock is released
+		var locked sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", lockName).Scan(&locked)
+		if err == nil && locked.Int64 != 1 {
+			err = ErrLockNotAcquired
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		m.log.ErrorContext(ctx, "locking schema_migrations", logging.Err(err))
+		return nil, err
+	}
+
+	return func() {
+		// the lock is released apart from ctx, that may be done already
+		if _, err := conn.ExecContext(context.Background(), release, lockName); err != nil {
+			m.log.ErrorContext(ctx, "unlocking schema_migrations", logging.Err(err))
+			// the session ends with the connection, releasing its locks,
+			// instead of going back to the pool
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}, nil
+}
@@ -0,0 +1,209 @@
+package migration
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"gorm.io/gorm"
+)
+
+type (
+	// Migration is a numbered schema change. Up applies it and Down reverts
+	// it, both run inside a transaction together with the schema_migrations
+	// bookkeeping (MySQL commits DDL statements implicitly).
+	Migration struct {
+		Version int64
+		Name    string
+		Up      func(tx *gorm.DB) error
+		Down    func(tx *gorm.DB) error
+	}
+
+	// Status is a migration and the moment it was applied, AppliedAt is nil
+	// while the migration is pending.
+	Status struct {
+		Version   int64
+		Name      string
+		AppliedAt *time.Time
+	}
+
+	Migrator interface {
+		// Up applies every pending migration in version order. Up and Down
+		// hold a database lock, on MySQL and Postgres, so only one process
+		// changes the schema at a time.
+		Up(ctx context.Context) error
+		// Down reverts the last applied migration.
+		Down(ctx context.Context) error
+		Status(ctx context.Context) ([]Status, error)
+	}
+
+	migrator struct {
+		db         *gorm.DB
+		log        *slog.Logger
+		migrations []Migration
+	}
+
+	// record is a row of the schema_migrations table.
+	record struct {
+		Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+		Name      string `gorm:"type:varchar(255);not null"`
+		AppliedAt time.Time
+	}
+)
+
+func (record) TableName() string {
+	return "schema_migrations"
+}
+
+func NewMigrator(db *gorm.DB, log *slog.Logger, migrations []Migration) Migrator {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	return &migrator{
+		db:         db,
+		log:        log,
+		migrations: sorted,
+	}
+}
+
+func (m *migrator) Up(ctx context.Context) error {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			m.log.ErrorContext(ctx, "applying migration", "version", migration.Version, "name", migration.Name, logging.Err(err))
+			return ErrMigrationFailed{Version: migration.Version, Name: migration.Name, Err: err}
+		}
+
+		m.log.InfoContext(ctx, "migration applied", "version", migration.Version, "name", migration.Name)
+	}
+
+	return nil
+}
+
+func (m *migrator) Down(ctx context.Context) error {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	var last *record
+	for _, r := range applied {
+		if last == nil || r.Version > last.Version {
+			last = r
+		}
+	}
+
+	if last == nil {
+		return ErrNoMigrationApplied
+	}
+
+	migration, ok := m.find(last.Version)
+	if !ok {
+		return ErrUnknownVersion{Version: last.Version}
+	}
+
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&record{}, migration.Version).Error
+	})
+	if err != nil {
+		m.log.ErrorContext(ctx, "reverting migration", "version", migration.Version, "name", migration.Name, logging.Err(err))
+		return ErrMigrationFailed{Version: migration.Version, Name: migration.Name, Err: err}
+	}
+
+	m.log.InfoContext(ctx, "migration reverted", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+func (m *migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := Status{Version: migration.Version, Name: migration.Name}
+		if r, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &r.AppliedAt
+			delete(applied, migration.Version)
+		}
+		status = append(status, s)
+	}
+
+	// versions applied by a newer build of the service
+	for _, r := range applied {
+		status = append(status, Status{Version: r.Version, Name: r.Name, AppliedAt: &r.AppliedAt})
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Version < status[j].Version
+	})
+
+	return status, nil
+}
+
+// applied returns the rows of schema_migrations by version, creating the
+// table the first time.
+func (m *migrator) applied(ctx context.Context) (map[int64]*record, error) {
+	db := m.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&record{}) {
+		if err := db.Migrator().CreateTable(&record{}); err != nil {
+			m.log.ErrorContext(ctx, "creating schema_migrations", logging.Err(err))
+			return nil, err
+		}
+	}
+
+	var records []record
+	if err := db.Order("version").Find(&records).Error; err != nil {
+		m.log.ErrorContext(ctx, "reading schema_migrations", logging.Err(err))
+		return nil, err
+	}
+
+	applied := make(map[int64]*record, len(records))
+	for i := range records {
+		applied[records[i].Version] = &records[i]
+	}
+	return applied, nil
+}
+
+func (m *migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
@@ -0,0 +1,251 @@
+package migration_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/migration"
+	"github.com/JuD4Mo/go_api_web_enrollment/pkg/bootstrap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var l = slog.New(slog.DiscardHandler)
+
+func newDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	// every connection to :memory: is a new database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	return db
+}
+
+func table(version int64, name string) migration.Migration {
+	return migration.Migration{
+		Version: version,
+		Name:    "create_" + name,
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE TABLE " + name + " (id INTEGER)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE " + name).Error
+		},
+	}
+}
+
+func versions(t *testing.T, m migration.Migrator) (applied, pending []int64) {
+	status, err := m.Status(context.Background())
+	require.NoError(t, err)
+
+	for _, s := range status {
+		if s.AppliedAt != nil {
+			applied = append(applied, s.Version)
+		} else {
+			pending = append(pending, s.Version)
+		}
+	}
+	return applied, pending
+}
+
+func TestUp(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("applies the pending migrations in version order", func(t *testing.T) {
+		db := newDB(t)
+		m := migration.NewMigrator(db, l, []migration.Migration{table(2, "b"), table(1, "a")})
+
+		applied, pending := versions(t, m)
+		assert.Nil(t, applied)
+		assert.Equal(t, []int64{1, 2}, pending)
+
+		require.NoError(t, m.Up(ctx))
+
+		applied, pending = versions(t, m)
+		assert.Equal(t, []int64{1, 2}, applied)
+		assert.Nil(t, pending)
+		assert.True(t, db.Migrator().HasTable("a"))
+		assert.True(t, db.Migrator().HasTable("b"))
+
+		// nothing left to apply
+		assert.NoError(t, m.Up(ctx))
+	})
+
+	t.Run("only applies the new migrations", func(t *testing.T) {
+		db := newDB(t)
+		require.NoError(t, migration.NewMigrator(db, l, []migration.Migration{table(1, "a")}).Up(ctx))
+
+		m := migration.NewMigrator(db, l, []migration.Migration{table(1, "a"), table(2, "b")})
+		require.NoError(t, m.Up(ctx))
+
+		applied, _ := versions(t, m)
+		assert.Equal(t, []int64{1, 2}, applied)
+	})
+
+	t.Run("stops at the failed migration", func(t *testing.T) {
+		db := newDB(t)
+		wantErr := errors.New("some error")
+		failing := migration.Migration{
+			Version: 2,
+			Name:    "failing",
+			Up: func(tx *gorm.DB) error {
+				if err := tx.Exec("CREATE TABLE c (id INTEGER)").Error; err != nil {
+					return err
+				}
+				return wantErr
+			},
+		}
+		m := migration.NewMigrator(db, l, []migration.Migration{table(1, "a"), failing, table(3, "b")})
+
+		err := m.Up(ctx)
+		assert.Equal(t, migration.ErrMigrationFailed{Version: 2, Name: "failing", Err: wantErr}, err)
+		assert.ErrorIs(t, err, wantErr)
+
+		applied, pending := versions(t, m)
+		assert.Equal(t, []int64{1}, applied)
+		assert.Equal(t, []int64{2, 3}, pending)
+		assert.False(t, db.Migrator().HasTable("c"))
+		assert.False(t, db.Migrator().HasTable("b"))
+	})
+}
+
+func TestDown(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reverts the last applied migration", func(t *testing.T) {
+		db := newDB(t)
+		m := migration.NewMigrator(db, l, []migration.Migration{table(1, "a"), table(2, "b")})
+		require.NoError(t, m.Up(ctx))
+
+		require.NoError(t, m.Down(ctx))
+
+		applied, pending := versions(t, m)
+		assert.Equal(t, []int64{1}, applied)
+		assert.Equal(t, []int64{2}, pending)
+		assert.True(t, db.Migrator().HasTable("a"))
+		assert.False(t, db.Migrator().HasTable("b"))
+
+		require.NoError(t, m.Down(ctx))
+		assert.False(t, db.Migrator().HasTable("a"))
+	})
+
+	t.Run("without applied migrations", func(t *testing.T) {
+		m := migration.NewMigrator(newDB(t), l, []migration.Migration{table(1, "a")})
+
+		assert.Equal(t, migration.ErrNoMigrationApplied, m.Down(ctx))
+	})
+
+	t.Run("with a migration unknown to the build", func(t *testing.T) {
+		db := newDB(t)
+		require.NoError(t, migration.NewMigrator(db, l, []migration.Migration{table(1, "a"), table(2, "b")}).Up(ctx))
+
+		m := migration.NewMigrator(db, l, []migration.Migration{table(1, "a")})
+		assert.Equal(t, migration.ErrUnknownVersion{Version: 2}, m.Down(ctx))
+
+		status, err := m.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, status, 2)
+		assert.Equal(t, "create_b", status[1].Name)
+		assert.NotNil(t, status[1].AppliedAt)
+	})
+}
+
+// driverDB opens the mysql or postgres database of REPOSITORY_TEST_DRIVER,
+// configured with the DATABASE_* envs, and skips the test without it.
+func driverDB(t *testing.T) *gorm.DB {
+	driver := os.Getenv("REPOSITORY_TEST_DRIVER")
+	if driver == "" {
+		t.Skip("REPOSITORY_TEST_DRIVER is not set")
+	}
+
+	dialector, err := bootstrap.Dialector(driver)
+	require.NoError(t, err)
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	return db
+}
+
+func TestMigrations(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testMigrations(t, newDB(t))
+	})
+
+	t.Run("driver", func(t *testing.T) {
+		testMigrations(t, driverDB(t))
+	})
+}
+
+func testMigrations(t *testing.T, db *gorm.DB) {
+	ctx := context.Background()
+	m := migration.NewMigrator(db, l, migration.Migrations())
+
+	require.NoError(t, m.Up(ctx))
+	assert.True(t, db.Migrator().HasTable("enrollments"))
+	assert.True(t, db.Migrator().HasColumn("enrollments", "deleted_at"))
+	for _, index := range migration.Indexes() {
+		assert.True(t, db.Migrator().HasIndex(index.Table, index.Name), index.Name)
+	}
+	assert.True(t, db.Migrator().HasTable("idempotency_keys"))
+
+	// every migration can be reverted and applied again
+	for range migration.Migrations() {
+		require.NoError(t, m.Down(ctx))
+	}
+	assert.False(t, db.Migrator().HasTable("enrollments"))
+	assert.False(t, db.Migrator().HasTable("idempotency_keys"))
+
+	require.NoError(t, m.Up(ctx))
+	applied, pending := versions(t, m)
+	assert.Len(t, applied, len(migration.Migrations()))
+	assert.Nil(t, pending)
+}
+
+func TestMigrationsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	db := driverDB(t)
+
+	m := migration.NewMigrator(db, l, migration.Migrations())
+	for {
+		err := m.Down(ctx)
+		if errors.Is(err, migration.ErrNoMigrationApplied) {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	// the lock lets a single migrator apply the migrations, the others find
+	// nothing left to apply
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = migration.NewMigrator(db, l, migration.Migrations()).Up(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	applied, pending := versions(t, m)
+	assert.Len(t, applied, len(migration.Migrations()))
+	assert.Nil(t, pending)
+}
@@ -0,0 +1,297 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The tables as they are after each migration. They are copies and not the
+// domain models so a migration keeps doing the same when the models change.
+type (
+	enrollmentV1 struct {
+		ID        string `gorm:"type:char(36);not null;primaryKey"`
+		UserID    string `gorm:"type:char(36)"`
+		CourseID  string `gorm:"type:char(36);not null"`
+		Status    string `gorm:"type:char(2)"`
+		CreatedAt *time.Time
+		UpdatedAt *time.Time
+	}
+
+	enrollmentV2 struct {
+		enrollmentV1
+		DeletedAt *time.Time
+	}
+
+	idempotencyKeyV1 struct {
+		Key         string `gorm:"column:idempotency_key;type:varchar(255);primaryKey"`
+		RequestHash string `gorm:"type:char(64);not null"`
+		StatusCode  int
+		Body        []byte
+		CreatedAt   time.Time
+	}
+
+	idempotencyKeyV2 struct {
+		Subject     string `gorm:"type:varchar(255);primaryKey"`
+		Key         string `gorm:"column:idempotency_key;type:varchar(255);primaryKey"`
+		RequestHash string `gorm:"type:char(64);not null"`
+		StatusCode  int
+		Body        []byte
+		CreatedAt   time.Time
+		ExpiresAt   time.Time `gorm:"index:idx_idempotency_keys_expires_at"`
+	}
+
+	courseCapacityV1 struct {
+		CourseID  string `gorm:"type:varchar(36);primaryKey"`
+		Seats     int    `gorm:"not null"`
+		CreatedAt *time.Time
+		UpdatedAt *time.Time
+	}
+
+	courseLockV1 struct {
+		CourseID string `gorm:"type:varchar(36);primaryKey"`
+	}
+)
+
+func (enrollmentV1) TableName() string {
+	return "enrollments"
+}
+
+func (enrollmentV2) TableName() string {
+	return "enrollments"
+}
+
+func (idempotencyKeyV1) TableName() string {
+	return "idempotency_keys"
+}
+
+func (idempotencyKeyV2) TableName() string {
+	return "idempotency_keys"
+}
+
+func (courseCapacityV1) TableName() string {
+	return "course_capacities"
+}
+
+func (courseLockV1) TableName() string {
+	return "course_locks"
+}
+
+// Migrations returns the migrations of the service. New migrations are added
+// at the end with the next version, applied ones must never be edited.
+func Migrations() []Migration {
+	return []Migration{
+		{
+			Version: 1,
+			Name:    "create_enrollments",
+			// databases created by the old AutoMigrate already have the table
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasTable(&enrollmentV1{}) {
+					return nil
+				}
+				return tx.Migrator().CreateTable(&enrollmentV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&enrollmentV1{})
+			},
+		},
+		{
+			Version: 2,
+			Name:    "add_enrollments_deleted_at",
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasColumn(&enrollmentV2{}, "deleted_at") {
+					return nil
+				}
+				return tx.Migrator().AddColumn(&enrollmentV2{}, "DeletedAt")
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&enrollmentV2{}, "DeletedAt")
+			},
+		},
+		{
+			Version: 3,
+			Name:    "add_enrollments_user_course_index",
+			// a user can only be enrolled once in each course
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasIndex(&enrollmentV2{}, "idx_enrollments_user_course") {
+					return nil
+				}
+				return tx.Exec("CREATE UNIQUE INDEX idx_enrollments_user_course ON enrollments (user_id, course_id)").Error
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropIndex(&enrollmentV2{}, "idx_enrollments_user_course")
+			},
+		},
+		{
+			Version: 4,
+			Name:    "create_idempotency_keys",
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasTable(&idempotencyKeyV1{}) {
+					return nil
+				}
+				return tx.Migrator().CreateTable(&idempotencyKeyV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&idempotencyKeyV1{})
+			},
+		},
+		{
+			Version: 5,
+			Name:    "add_enrollments_query_indexes",
+			Up: func(tx *gorm.DB) error {
+				for _, index := range queryIndexes {
+					if tx.Migrator().HasIndex(index.Table, index.Name) {
+						continue
+					}
+					if err := tx.Exec(index.create()).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Down: func(tx *gorm.DB) error {
+				for _, index := range queryIndexes {
+					if err := tx.Migrator().DropIndex(index.Table, index.Name); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Version: 6,
+			Name:    "create_course_capacities",
+			// the seats of the courses that do not use the default COURSE_CAPACITY
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasTable(&courseCapacityV1{}) {
+					return nil
+				}
+				return tx.Migrator().CreateTable(&courseCapacityV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&courseCapacityV1{})
+			},
+		},
+		{
+			Version: 7,
+			Name:    "scope_idempotency_keys",
+			// the keys are scoped to the subject of the caller and expire, the
+			// stored responses are only kept for retries so the table is
+			// recreated instead of migrating its rows
+			Up: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&idempotencyKeyV1{}); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateTable(&idempotencyKeyV2{})
+			},
+			Down: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&idempotencyKeyV2{}); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateTable(&idempotencyKeyV1{})
+			},
+		},
+		{
+			Version: 8,
+			Name:    "use_varchar_enrollment_columns",
+			// Postgres pads char(n) with spaces, a "P" status was read back as
+			// "P " and the ids did not match the ones of the other services.
+			// SQLite does not pad and keeps its columns.
+			Up: func(tx *gorm.DB) error {
+				return alterEnrollmentColumns(tx, "varchar")
+			},
+			Down: func(tx *gorm.DB) error {
+				return alterEnrollmentColumns(tx, "char")
+			},
+		},
+		{
+			Version: 9,
+			Name:    "create_course_locks",
+			// a row per course locked by the transactions that take or free
+			// its seats, courses on the default COURSE_CAPACITY have no row
+			// in course_capacities to lock
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasTable(&courseLockV1{}) {
+					return nil
+				}
+				return tx.Migrator().CreateTable(&courseLockV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&courseLockV1{})
+			},
+		},
+	}
+}
+
+// alterEnrollmentColumns changes the type of the id, user_id, course_id and
+// status columns of enrollments to kind, char or varchar, keeping their
+// lengths. The values are trimmed, the padding of char(n) is not data.
+func alterEnrollmentColumns(tx *gorm.DB, kind string) error {
+	columns := []struct {
+		name    string
+		length  int
+		notNull bool
+	}{
+		{name: "id", length: 36, notNull: true},
+		{name: "user_id", length: 36},
+		{name: "course_id", length: 36, notNull: true},
+		{name: "status", length: 2},
+	}
+
+	var changes []string
+	switch tx.Dialector.Name() {
+	case "postgres":
+		for _, c := range columns {
+			changes = append(changes, fmt.Sprintf("ALTER COLUMN %s TYPE %s(%d) USING rtrim(%s)", c.name, kind, c.length, c.name))
+		}
+	case "mysql":
+		for _, c := range columns {
+			null := "NULL"
+			if c.notNull {
+				null = "NOT NULL"
+			}
+			changes = append(changes, fmt.Sprintf("MODIFY %s %s(%d) %s", c.name, kind, c.length, null))
+		}
+	default:
+		return nil
+	}
+
+	return tx.Exec("ALTER TABLE enrollments " + strings.Join(changes, ", ")).Error
+}
+
+// Index is an index the enrollment queries rely on.
+type Index struct {
+	Table   string
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+func (i Index) create() string {
+	unique := ""
+	if i.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, i.Name, i.Table, strings.Join(i.Columns, ", "))
+}
+
+// queryIndexes are the indexes added by add_enrollments_query_indexes:
+//   - course_id, status, created_at: the course filter, the seats count by
+//     status and the waitlist in queue order
+//   - created_at, id: the default order of GetAll and its cursor pagination
+var queryIndexes = []Index{
+	{Table: "enrollments", Name: "idx_enrollments_course_status_created", Columns: []string{"course_id", "status", "created_at"}},
+	{Table: "enrollments", Name: "idx_enrollments_created_id", Columns: []string{"created_at", "id"}},
+}
+
+// Indexes returns the indexes the enrollment queries need once every
+// migration is applied. The user_id filter uses the unique (user_id,
+// course_id) index.
+func Indexes() []Index {
+	indexes := []Index{
+		{Table: "enrollments", Name: "idx_enrollments_user_course", Columns: []string{"user_id", "course_id"}, Unique: true},
+	}
+	return append(indexes, queryIndexes...)
+}
@@ -0,0 +1,11 @@
+package ratelimit
+
+import "fmt"
+
+type ErrInvalidRoute struct {
+	Route string
+}
+
+func (e ErrInvalidRoute) Error() string {
+	return fmt.Sprintf("invalid rate limit route '%s', expected 'METHOD /path=requests/period'", e.Route)
+}
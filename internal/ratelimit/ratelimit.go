@@ -0,0 +1,338 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_lib_response/response"
+)
+
+// Headers of the limited responses.
+const (
+	LimitHeader      = "X-RateLimit-Limit"
+	RemainingHeader  = "X-RateLimit-Remaining"
+	ResetHeader      = "X-RateLimit-Reset"
+	RetryAfterHeader = "Retry-After"
+)
+
+// AnyMethod matches the requests of every method.
+const AnyMethod = "*"
+
+// DefaultMaxBuckets bounds the memory of a limiter, about 100 bytes per
+// bucket.
+const DefaultMaxBuckets = 100_000
+
+type (
+	// Rule allows Requests per Period to every client, in bursts of up to
+	// Requests.
+	Rule struct {
+		Requests int
+		Period   time.Duration
+	}
+
+	// Route applies the Rule to the requests of Method whose path is Path or
+	// is below it.
+	Route struct {
+		Method string
+		Path   string
+		Rule   Rule
+	}
+
+	// KeyFunc identifies the client of the request, every client has its own
+	// buckets.
+	KeyFunc func(r *http.Request) string
+
+	// Result is the state of the bucket of the client after a request.
+	Result struct {
+		Allowed   bool
+		Limit     int
+		Remaining int
+		// Reset is the time until the bucket is full again
+		Reset time.Duration
+		// RetryAfter is the time until the next request is allowed, zero
+		// when the request was allowed
+		RetryAfter time.Duration
+	}
+
+	// Limiter is a token bucket rate limiter, the first route matching the
+	// request sets its rule and requests matching no route are not limited.
+	// It keeps at most maxBuckets buckets, a new client evicts the least
+	// recently used one.
+	Limiter struct {
+		routes     []Route
+		key        KeyFunc
+		now        func() time.Time
+		log        *slog.Logger
+		maxBuckets int
+
+		mu      sync.Mutex
+		buckets map[bucketKey]*bucket
+		// lru orders the keys of the buckets from the most to the least
+		// recently used
+		lru *list.List
+	}
+
+	bucketKey struct {
+		route  int
+		client string
+	}
+
+	bucket struct {
+		tokens  float64
+		updated time.Time
+		elem    *list.Element
+	}
+)
+
+func NewLimiter(log *slog.Logger, key KeyFunc, routes ...Route) *Limiter {
+	return &Limiter{
+		routes:     routes,
+		key:        key,
+		now:        time.Now,
+		log:        log,
+		maxBuckets: DefaultMaxBuckets,
+		buckets:    make(map[bucketKey]*bucket),
+		lru:        list.New(),
+	}
+}
+
+// WithClock replaces the clock of the limiter, meant for the tests.
+func (l *Limiter) WithClock(now func() time.Time) *Limiter {
+	l.now = now
+	return l
+}
+
+// WithMaxBuckets replaces DefaultMaxBuckets, the number of buckets kept.
+func (l *Limiter) WithMaxBuckets(n int) *Limiter {
+	l.maxBuckets = n
+	return l
+}
+
+// Allow takes a token from the bucket of the client for the route matching
+// the request, ok is false when no route matches.
+func (l *Limiter) Allow(method, path, client string) (result Result, ok bool) {
+	i, route, ok := l.route(method, path)
+	if !ok {
+		return Result{}, false
+	}
+	rule := route.Rule
+	burst := float64(rule.Requests)
+	rate := burst / rule.Period.Seconds()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key := bucketKey{route: i, client: client}
+	b, found := l.buckets[key]
+	if found {
+		l.lru.MoveToFront(b.elem)
+	} else {
+		// the evicted client starts over with a full bucket, the least
+		// recently used one is the closest to being full again anyway
+		for len(l.buckets) >= max(l.maxBuckets, 1) {
+			l.remove(l.lru.Back())
+		}
+		b = &bucket{tokens: burst, updated: now}
+		b.elem = l.lru.PushFront(key)
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	result = Result{Limit: rule.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((burst - b.tokens) / rate)
+	return result, true
+}
+
+// Middleware answers 429 to the requests over the limit of their client and
+// sets the X-RateLimit-* headers on the limited routes.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the client is only identified on the limited routes
+		if _, _, ok := l.route(r.Method, r.URL.Path); !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := l.key(r)
+		result, _ := l.Allow(r.Method, r.URL.Path, client)
+
+		w.Header().Set(LimitHeader, strconv.Itoa(result.Limit))
+		w.Header().Set(RemainingHeader, strconv.Itoa(result.Remaining))
+		w.Header().Set(ResetHeader, strconv.Itoa(int(result.Reset.Seconds())))
+
+		if !result.Allowed {
+			l.log.WarnContext(r.Context(), "rate limit exceeded",
+				"client", client, "method", r.Method, "path", r.URL.Path)
+
+			w.Header().Set(RetryAfterHeader, strconv.Itoa(int(result.RetryAfter.Seconds())))
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_ = json.NewEncoder(w).Encode(response.ErrorResponse{
+				Status:  http.StatusTooManyRequests,
+				Message: fmt.Sprintf("rate limit exceeded, retry in %d seconds", int(result.RetryAfter.Seconds())),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) route(method, path string) (int, Route, bool) {
+	for i, route := range l.routes {
+		if route.Method != AnyMethod && route.Method != "" && !strings.EqualFold(route.Method, method) {
+			continue
+		}
+		prefix := strings.TrimSuffix(route.Path, "/")
+		if path == route.Path || strings.HasPrefix(path, prefix+"/") {
+			return i, route, true
+		}
+	}
+	return 0, Route{}, false
+}
+
+// Len returns the number of buckets kept.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// Sweep drops the buckets left unused for a whole period, they are full again
+// and equal to a new bucket. It walks the buckets from the least recently
+// used and stops at the first one used within the shortest period.
+func (l *Limiter) Sweep() {
+	shortest := time.Duration(math.MaxInt64)
+	for _, route := range l.routes {
+		shortest = min(shortest, route.Rule.Period)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for elem := l.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		key := elem.Value.(bucketKey)
+		idle := now.Sub(l.buckets[key].updated)
+		if idle < shortest {
+			break
+		}
+		if idle >= l.routes[key.route].Rule.Period {
+			l.remove(elem)
+		}
+		elem = prev
+	}
+}
+
+// Cleanup sweeps the buckets every interval until ctx is done, so the
+// requests never pay for it.
+func (l *Limiter) Cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.Sweep()
+		}
+	}
+}
+
+func (l *Limiter) remove(elem *list.Element) {
+	delete(l.buckets, l.lru.Remove(elem).(bucketKey))
+}
+
+// ClientKey identifies the clients by the subject of their bearer token, when
+// auth.Middleware verified it, and otherwise by their IP. With trustProxy the
+// IP is the last one of X-Forwarded-For, the address seen by the proxy in
+// front of the service, instead of the address of the connection.
+func ClientKey(trustProxy bool) KeyFunc {
+	return func(r *http.Request) string {
+		if subject := auth.VerifiedSubject(r.Context()); subject != "" {
+			return "subject:" + subject
+		}
+		return "ip:" + clientIP(r, trustProxy)
+	}
+}
+
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			ips := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(ips[len(ips)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ParseRoutes reads routes written as "METHOD /path=requests/period"
+// separated by commas, e.g. "POST /enrollments=20/1m,* /enrollments=120/1m".
+func ParseRoutes(s string) ([]Route, error) {
+	var routes []Route
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		target, limit, ok := strings.Cut(r, "=")
+		fields := strings.Fields(target)
+		if !ok || len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+			return nil, ErrInvalidRoute{Route: r}
+		}
+
+		requests, period, ok := strings.Cut(strings.TrimSpace(limit), "/")
+		n, err := strconv.Atoi(requests)
+		if !ok || err != nil || n <= 0 {
+			return nil, ErrInvalidRoute{Route: r}
+		}
+		d, err := time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidRoute{Route: r}
+		}
+
+		routes = append(routes, Route{
+			Method: strings.ToUpper(fields[0]),
+			Path:   fields[1],
+			Rule:   Rule{Requests: n, Period: d},
+		})
+	}
+	return routes, nil
+}
+
+// seconds rounds up to whole seconds, the unit of the headers, so a client
+// waiting for the time it was told never finds the bucket empty.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s-1e-9)) * time.Second
+}
@@ -0,0 +1,246 @@
+package ratelimit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/ratelimit"
+	"github.com/JuD4Mo/go_lib_response/response"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type verifierMock struct {
+	VerifyMock func(token string) (*auth.Claims, error)
+}
+
+func (v *verifierMock) Verify(token string) (*auth.Claims, error) {
+	return v.VerifyMock(token)
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newLimiter(c *clock, routes ...ratelimit.Route) *ratelimit.Limiter {
+	return ratelimit.NewLimiter(slog.New(slog.DiscardHandler), ratelimit.ClientKey(false), routes...).WithClock(c.Now)
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	limiter := newLimiter(c,
+		ratelimit.Route{Method: http.MethodPost, Path: "/enrollments", Rule: ratelimit.Rule{Requests: 2, Period: time.Minute}},
+		ratelimit.Route{Method: ratelimit.AnyMethod, Path: "/enrollments", Rule: ratelimit.Rule{Requests: 4, Period: time.Minute}},
+	)
+
+	t.Run("allows a burst and then rejects", func(t *testing.T) {
+		for remaining := 1; remaining >= 0; remaining-- {
+			result, ok := limiter.Allow(http.MethodPost, "/enrollments", "a")
+			require.True(t, ok)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 2, result.Limit)
+			assert.Equal(t, remaining, result.Remaining)
+		}
+
+		result, _ := limiter.Allow(http.MethodPost, "/enrollments", "a")
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 30*time.Second, result.RetryAfter)
+		assert.Equal(t, time.Minute, result.Reset)
+	})
+
+	t.Run("keeps a bucket per client and route", func(t *testing.T) {
+		result, _ := limiter.Allow(http.MethodPost, "/enrollments", "b")
+		assert.True(t, result.Allowed)
+
+		result, _ = limiter.Allow(http.MethodGet, "/enrollments/10", "a")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 4, result.Limit)
+		assert.Equal(t, 3, result.Remaining)
+	})
+
+	t.Run("refills the bucket over time", func(t *testing.T) {
+		c.now = c.now.Add(30 * time.Second)
+		result, _ := limiter.Allow(http.MethodPost, "/enrollments", "a")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+
+		c.now = c.now.Add(time.Hour)
+		result, _ = limiter.Allow(http.MethodPost, "/enrollments", "a")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 1, result.Remaining)
+	})
+
+	t.Run("does not limit the other routes", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/metrics", "/enrollmentsx"} {
+			_, ok := limiter.Allow(http.MethodGet, path, "a")
+			assert.False(t, ok, path)
+		}
+	})
+}
+
+func TestLimiter_MaxBuckets(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	limiter := newLimiter(c, ratelimit.Route{Path: "/enrollments", Rule: ratelimit.Rule{Requests: 2, Period: time.Minute}}).WithMaxBuckets(2)
+
+	limiter.Allow(http.MethodGet, "/enrollments", "a")
+	limiter.Allow(http.MethodGet, "/enrollments", "b")
+	limiter.Allow(http.MethodGet, "/enrollments", "a")
+
+	// b is the least recently used bucket, c evicts it
+	limiter.Allow(http.MethodGet, "/enrollments", "c")
+	assert.Equal(t, 2, limiter.Len())
+
+	result, _ := limiter.Allow(http.MethodGet, "/enrollments", "a")
+	assert.False(t, result.Allowed, "a kept its empty bucket")
+
+	result, _ = limiter.Allow(http.MethodGet, "/enrollments", "b")
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining, "b starts over")
+	assert.Equal(t, 2, limiter.Len())
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	limiter := newLimiter(c,
+		ratelimit.Route{Method: http.MethodPost, Path: "/enrollments", Rule: ratelimit.Rule{Requests: 2, Period: time.Minute}},
+		ratelimit.Route{Path: "/enrollments", Rule: ratelimit.Rule{Requests: 2, Period: time.Hour}},
+	)
+
+	limiter.Allow(http.MethodPost, "/enrollments", "a")
+	limiter.Allow(http.MethodGet, "/enrollments", "a")
+	c.now = c.now.Add(30 * time.Second)
+	limiter.Allow(http.MethodPost, "/enrollments", "b")
+	require.Equal(t, 3, limiter.Len())
+
+	limiter.Sweep()
+	assert.Equal(t, 3, limiter.Len(), "no bucket is idle for its period")
+
+	c.now = c.now.Add(45 * time.Second)
+	limiter.Sweep()
+	assert.Equal(t, 2, limiter.Len(), "drops the POST bucket of a")
+
+	c.now = c.now.Add(time.Hour)
+	limiter.Sweep()
+	assert.Zero(t, limiter.Len())
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	limiter := newLimiter(c, ratelimit.Route{Path: "/enrollments", Rule: ratelimit.Rule{Requests: 1, Period: 10 * time.Second}})
+	h := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "10.0.0.1:5000"
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("/enrollments")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get(ratelimit.LimitHeader))
+	assert.Equal(t, "0", w.Header().Get(ratelimit.RemainingHeader))
+	assert.Equal(t, "10", w.Header().Get(ratelimit.ResetHeader))
+	assert.Empty(t, w.Header().Get(ratelimit.RetryAfterHeader))
+
+	w = serve("/enrollments")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get(ratelimit.RetryAfterHeader))
+	assert.Equal(t, "0", w.Header().Get(ratelimit.RemainingHeader))
+
+	var body response.ErrorResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, http.StatusTooManyRequests, body.Status)
+	assert.NotEmpty(t, body.Message)
+
+	w = serve("/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(ratelimit.LimitHeader))
+}
+
+func TestClientKey(t *testing.T) {
+	verifier := &verifierMock{
+		VerifyMock: func(token string) (*auth.Claims, error) {
+			if token != "valid" {
+				return nil, auth.ErrInvalidToken{Err: errors.New("token is expired")}
+			}
+			return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u1"}}, nil
+		},
+	}
+
+	tests := []struct {
+		name          string
+		verifier      auth.Verifier
+		trustProxy    bool
+		authorization string
+		forwarded     []string
+		expected      string
+	}{
+		{name: "subject of a valid token", verifier: verifier, authorization: "Bearer valid", expected: "subject:u1"},
+		{name: "IP with an invalid token", verifier: verifier, authorization: "Bearer expired", expected: "ip:10.0.0.1"},
+		{name: "IP without verified token", authorization: "Bearer valid", expected: "ip:10.0.0.1"},
+		{name: "ignores X-Forwarded-For", forwarded: []string{"1.1.1.1"}, expected: "ip:10.0.0.1"},
+		{name: "last X-Forwarded-For from a proxy", trustProxy: true, forwarded: []string{"1.1.1.1, 2.2.2.2", "3.3.3.3"}, expected: "ip:3.3.3.3"},
+		{name: "connection without X-Forwarded-For", trustProxy: true, expected: "ip:10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/enrollments", nil)
+			r.RemoteAddr = "10.0.0.1:5000"
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			for _, f := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+
+			var key string
+			var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key = ratelimit.ClientKey(tt.trustProxy)(r)
+			})
+			if tt.verifier != nil {
+				h = auth.Middleware(tt.verifier)(h)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			assert.Equal(t, tt.expected, key)
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ratelimit.ParseRoutes("post /enrollments=20/1m, * /enrollments=120/1m,")
+	require.NoError(t, err)
+	assert.Equal(t, []ratelimit.Route{
+		{Method: http.MethodPost, Path: "/enrollments", Rule: ratelimit.Rule{Requests: 20, Period: time.Minute}},
+		{Method: ratelimit.AnyMethod, Path: "/enrollments", Rule: ratelimit.Rule{Requests: 120, Period: time.Minute}},
+	}, routes)
+
+	for _, invalid := range []string{
+		"/enrollments=20/1m",
+		"POST enrollments=20/1m",
+		"POST /enrollments",
+		"POST /enrollments=20",
+		"POST /enrollments=0/1m",
+		"POST /enrollments=20/0s",
+		"POST /enrollments=x/1m",
+	} {
+		_, err := ratelimit.ParseRoutes(invalid)
+		assert.ErrorAs(t, err, &ratelimit.ErrInvalidRoute{}, invalid)
+	}
+}
@@ -0,0 +1,63 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID sent by the callers and echoed in the
+// responses. It is not forwarded to the user and course services, their SDK
+// clients build the requests without the context and with their own
+// http.Client.
+const Header = "X-Request-ID"
+
+// maxLength bounds the IDs accepted from the callers, longer ones are
+// replaced by a generated ID.
+const maxLength = 128
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID of ctx, empty when there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware keeps the X-Request-ID sent by the caller, or generates one,
+// stores it in the request context, tags the lines logged during the request
+// with it and echoes it in the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(Header, id)
+		ctx := logging.With(NewContext(r.Context(), id), logging.RequestIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// valid reports whether id can be used as a request ID: printable ASCII
+// without spaces, so it is safe to log and to send in a header.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package requestid_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{name: "keeps the id of the caller", header: "abc-123", expected: "abc-123"},
+		{name: "generates a missing id"},
+		{name: "replaces an id with spaces", header: "abc 123"},
+		{name: "replaces a long id", header: strings.Repeat("a", 129)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			h := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = requestid.FromContext(r.Context())
+				w.WriteHeader(http.StatusNotFound)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/enrollments", nil)
+			if tt.header != "" {
+				req.Header.Set(requestid.Header, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(requestid.Header)
+			assert.Equal(t, fromContext, id)
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, id)
+			} else {
+				assert.Len(t, id, 36)
+				assert.NotEqual(t, tt.header, id)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Logging(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := logging.New(&buf, "json", "info")
+	require.NoError(t, err)
+
+	h := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.InfoContext(r.Context(), "handled")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/enrollments", nil)
+	req.Header.Set(requestid.Header, "abc 123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	// the logged id is the validated one, never the rejected header
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, rec.Header().Get(requestid.Header), line[logging.RequestIDKey])
+	assert.NotEqual(t, "abc 123", line[logging.RequestIDKey])
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	instrumentationName = "github.com/JuD4Mo/go_api_web_enrollment/internal/tracing"
+	spanKey             = "tracing:span"
+)
+
+// GormPlugin creates a span for every statement run through the db, as a
+// child of the span in the context given to WithContext.
+type GormPlugin struct {
+	// Provider creates the spans, the global provider is used when nil.
+	Provider trace.TracerProvider
+}
+
+func (p GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("tracing:before_create", p.before("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", after),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", p.before("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", after),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", p.before("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", p.before("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", p.before("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", p.before("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p GormPlugin) tracer() trace.Tracer {
+	if p.Provider != nil {
+		return p.Provider.Tracer(instrumentationName)
+	}
+	return otel.Tracer(instrumentationName)
+}
+
+func (p GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		_, span := p.tracer().Start(db.Statement.Context, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient))
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func after(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("db.system.name", db.Dialector.Name()),
+		attribute.String("db.collection.name", db.Statement.Table),
+		attribute.String("db.query.text", db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
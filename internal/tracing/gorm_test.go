@@ -0,0 +1,74 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type item struct {
+	ID   int
+	Name string
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(tracing.GormPlugin{Provider: provider}))
+	require.NoError(t, db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)").Error)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	require.NoError(t, db.WithContext(ctx).Create(&item{ID: 1, Name: "a"}).Error)
+
+	var found item
+	require.NoError(t, db.WithContext(ctx).First(&found, 1).Error)
+
+	// record not found is an expected result, not an error
+	assert.ErrorIs(t, db.WithContext(ctx).First(&found, 2).Error, gorm.ErrRecordNotFound)
+
+	assert.Error(t, db.WithContext(ctx).Exec("SELECT * FROM unknown").Error)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 6)
+
+	tests := []struct {
+		name   string
+		status codes.Code
+	}{
+		{"gorm.raw", codes.Unset},
+		{"gorm.create", codes.Unset},
+		{"gorm.query", codes.Unset},
+		{"gorm.query", codes.Unset},
+		{"gorm.raw", codes.Error},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		assert.Equal(t, tt.name, span.Name(), i)
+		assert.Equal(t, tt.status, span.Status().Code, i)
+		if i > 0 {
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID(), i)
+		}
+	}
+
+	attrs := map[string]string{}
+	for _, attr := range spans[1].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	assert.Equal(t, "sqlite", attrs["db.system.name"])
+	assert.Equal(t, "items", attrs["db.collection.name"])
+	assert.Contains(t, attrs["db.query.text"], "INSERT INTO `items`")
+	assert.Equal(t, "1", attrs["db.rows_affected"])
+}
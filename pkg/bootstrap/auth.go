@@ -0,0 +1,61 @@
+package bootstrap
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// InitAuth builds the verifier of the bearer tokens from the AUTH_* envs:
+// AUTH_JWT_SECRET enables HS256, AUTH_JWT_PUBLIC_KEY (PEM, identified by
+// AUTH_JWT_KEY_ID) and AUTH_JWKS_FILE enable RS256. It returns a nil verifier
+// when AUTH_DISABLED is true.
+func InitAuth() (auth.Verifier, error) {
+	if os.Getenv("AUTH_DISABLED") == "true" {
+		return nil, nil
+	}
+
+	config := auth.Config{
+		Secret:   []byte(os.Getenv("AUTH_JWT_SECRET")),
+		Keys:     make(map[string]*rsa.PublicKey),
+		Issuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		Audience: os.Getenv("AUTH_JWT_AUDIENCE"),
+	}
+
+	if l := os.Getenv("AUTH_JWT_LEEWAY"); l != "" {
+		leeway, err := time.ParseDuration(l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_JWT_LEEWAY: %w", err)
+		}
+		config.Leeway = leeway
+	}
+
+	if path := os.Getenv("AUTH_JWKS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := auth.ParseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			config.Keys[kid] = key
+		}
+	}
+
+	if pem := os.Getenv("AUTH_JWT_PUBLIC_KEY"); pem != "" {
+		key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pem))
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_JWT_PUBLIC_KEY: %w", err)
+		}
+		config.Keys[os.Getenv("AUTH_JWT_KEY_ID")] = key
+	}
+
+	return auth.NewVerifier(config)
+}
@@ -1,46 +1,129 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"strings"
 
-	"github.com/JuD4Mo/go_api_web_domain/domain"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/migration"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/tracing"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func DBConnection() (*gorm.DB, error) {
-	//Contruímos el string de conexión a la bd por medio de los envs
-	dsn := fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local",
-		os.Getenv("DATABASE_USER"),
-		os.Getenv("DATABASE_PASSWORD"),
-		os.Getenv("DATABASE_HOST"),
-		os.Getenv("DATABASE_PORT"),
-		os.Getenv("DATABASE_NAME"),
-	)
+func DBConnection(log *slog.Logger) (*gorm.DB, error) {
+	db, err := Open()
+	if err != nil {
+		return nil, err
+	}
 
-	//Abrimos la instancia de base de datos por medio de GORM y la inicializamos en modo debug
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if os.Getenv("DATABASE_MIGRATE") == "true" {
+		if err := Migrate(db, log); err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// Open connects to the database of the DATABASE_* envs without migrating it.
+func Open() (*gorm.DB, error) {
+	//DATABASE_DRIVER elige el motor de base de datos, por defecto MySQL
+	driver := os.Getenv("DATABASE_DRIVER")
+	dialector, err := Dialector(driver)
 	if err != nil {
 		return nil, err
 	}
 
-	if os.Getenv("DATABASE_DEBUG") == "true" {
-		db = db.Debug()
+	//Abrimos la instancia de base de datos por medio de GORM y la inicializamos en modo debug
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
 	}
 
-	if os.Getenv("DATABASE_MIGRATE") == "true" {
-		//Migra el "modelo" a una tabla SQL
-		err := db.AutoMigrate(&domain.Enrollment{})
+	//SQLite admite una sola escritura a la vez, usamos una única conexión
+	if driver == "sqlite" {
+		sqlDB, err := db.DB()
 		if err != nil {
 			return nil, err
 		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	//Cada consulta genera un span de OpenTelemetry
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+
+	if os.Getenv("DATABASE_DEBUG") == "true" {
+		db = db.Debug()
 	}
 
 	return db, nil
 }
 
-func InitLogger() *log.Logger {
-	return log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
+// Dialector builds the GORM dialector of the driver ("mysql", "postgres" or
+// "sqlite") with the connection values of the DATABASE_* envs. An empty driver
+// means MySQL. SQLite uses DATABASE_NAME as the path of the database file.
+func Dialector(driver string) (gorm.Dialector, error) {
+	switch driver {
+	case "", "mysql":
+		//Contruímos el string de conexión a la bd por medio de los envs
+		//clientFoundRows hace que RowsAffected cuente las filas encontradas y no solo las modificadas, como en postgres
+		dsn := fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local&clientFoundRows=true",
+			os.Getenv("DATABASE_USER"),
+			os.Getenv("DATABASE_PASSWORD"),
+			os.Getenv("DATABASE_HOST"),
+			os.Getenv("DATABASE_PORT"),
+			os.Getenv("DATABASE_NAME"),
+		)
+		return mysql.Open(dsn), nil
+	case "postgres":
+		sslMode := os.Getenv("DATABASE_SSLMODE")
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(os.Getenv("DATABASE_USER"), os.Getenv("DATABASE_PASSWORD")),
+			Host:     net.JoinHostPort(os.Getenv("DATABASE_HOST"), os.Getenv("DATABASE_PORT")),
+			Path:     os.Getenv("DATABASE_NAME"),
+			RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+		}
+		return postgres.Open(dsn.String()), nil
+	case "sqlite":
+		return sqlite.Open(os.Getenv("DATABASE_NAME")), nil
+	}
+
+	return nil, fmt.Errorf("unknown database driver %q", driver)
+}
+
+// Migrate applies the pending migrations of the database.
+func Migrate(db *gorm.DB, log *slog.Logger) error {
+	return migration.NewMigrator(db, log, migration.Migrations()).Up(context.Background())
+}
+
+// CheckIndexes warns about the indexes of the enrollment queries that are
+// missing, usually because the migrations were not applied.
+func CheckIndexes(db *gorm.DB, log *slog.Logger) {
+	for _, index := range migration.Indexes() {
+		if !db.Migrator().HasIndex(index.Table, index.Name) {
+			log.Warn("index is missing, run the migrations",
+				"index", index.Name, "table", index.Table, "columns", strings.Join(index.Columns, ", "))
+		}
+	}
+}
+
+// InitLogger builds the structured logger of the service, LOG_FORMAT selects
+// "json" (the default) or "text" lines and LOG_LEVEL the minimum level.
+func InitLogger() (*slog.Logger, error) {
+	return logging.New(os.Stdout, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
 }
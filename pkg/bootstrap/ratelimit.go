@@ -0,0 +1,47 @@
+package bootstrap
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/ratelimit"
+)
+
+// defaultRateLimitRoutes is stricter on the POST requests, which create
+// enrollments and call the user and course services.
+const defaultRateLimitRoutes = "POST /enrollments=20/1m,* /enrollments=120/1m"
+
+// InitRateLimit builds the rate limiter of the HTTP server from the
+// RATE_LIMIT_* envs: RATE_LIMIT_ROUTES lists the routes and their limits and
+// RATE_LIMIT_TRUST_PROXY reads the client IP from X-Forwarded-For and
+// RATE_LIMIT_MAX_BUCKETS bounds the buckets kept in memory. The clients with
+// a token verified by auth.Middleware are limited by subject. It returns a
+// nil limiter when RATE_LIMIT_DISABLED is true.
+func InitRateLimit(l *slog.Logger) (*ratelimit.Limiter, error) {
+	if os.Getenv("RATE_LIMIT_DISABLED") == "true" {
+		return nil, nil
+	}
+
+	r := os.Getenv("RATE_LIMIT_ROUTES")
+	if r == "" {
+		r = defaultRateLimitRoutes
+	}
+	routes, err := ratelimit.ParseRoutes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	key := ratelimit.ClientKey(os.Getenv("RATE_LIMIT_TRUST_PROXY") == "true")
+	limiter := ratelimit.NewLimiter(l, key, routes...)
+
+	if m := os.Getenv("RATE_LIMIT_MAX_BUCKETS"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_MAX_BUCKETS %q", m)
+		}
+		limiter = limiter.WithMaxBuckets(n)
+	}
+	return limiter, nil
+}
@@ -0,0 +1,63 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// InitTracing sets the global tracer provider and the W3C trace context
+// propagator. OTEL_TRACES_EXPORTER selects where the spans go:
+//   - "stdout": printed as JSON
+//   - "otlp": sent to an OTLP/HTTP collector, configured with the standard
+//     OTEL_EXPORTER_OTLP_* envs
+//   - empty or "none": not exported, incoming trace context is still propagated
+//
+// The returned function flushes the pending spans and must be called before
+// the process exits.
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+	//Propagamos el contexto de traza W3C recibido en las cabeceras
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exp := os.Getenv("OTEL_TRACES_EXPORTER"); exp {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", exp)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//OTEL_SERVICE_NAME tiene prioridad sobre el nombre por defecto
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("enrollment")),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/auth"
 	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/health"
 
 	"github.com/JuD4Mo/go_lib_response/response"
 	"github.com/go-kit/kit/endpoint"
@@ -15,11 +19,29 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func NewEnrollmentHTTPServer(ctx context.Context, endpoints enrollment.Endpoints) http.Handler {
+// NewEnrollmentHTTPServer builds the router of the service, extra options
+// such as InstrumentingOptions are added to every route.
+func NewEnrollmentHTTPServer(ctx context.Context, endpoints enrollment.Endpoints, healthEndpoints health.Endpoints, extra ...httptransport.ServerOption) http.Handler {
 	r := mux.NewRouter()
 	opts := []httptransport.ServerOption{
+		httptransport.ServerBefore(auth.HTTPToContext),
 		httptransport.ServerErrorEncoder(encodedError),
 	}
+	opts = append(opts, extra...)
+
+	r.Handle("/healthz", httptransport.NewServer(
+		endpoint.Endpoint(healthEndpoints.Live),
+		decodeHealth,
+		encodeResponse,
+		opts...,
+	)).Methods("GET")
+
+	r.Handle("/readyz", httptransport.NewServer(
+		endpoint.Endpoint(healthEndpoints.Ready),
+		decodeHealth,
+		encodeResponse,
+		opts...,
+	)).Methods("GET")
 
 	r.Handle("/enrollments", httptransport.NewServer(
 		endpoint.Endpoint(endpoints.Create),
@@ -28,6 +50,13 @@ func NewEnrollmentHTTPServer(ctx context.Context, endpoints enrollment.Endpoints
 		opts...,
 	)).Methods("POST")
 
+	r.Handle("/enrollments/bulk", httptransport.NewServer(
+		endpoint.Endpoint(endpoints.CreateBulk),
+		decodeCreateBulkEnrollment,
+		encodeResponse,
+		opts...,
+	)).Methods("POST")
+
 	r.Handle("/enrollments", httptransport.NewServer(
 		endpoint.Endpoint(endpoints.GetAll),
 		decodeGetAllEnrollment,
@@ -35,6 +64,27 @@ func NewEnrollmentHTTPServer(ctx context.Context, endpoints enrollment.Endpoints
 		opts...,
 	)).Methods("GET")
 
+	r.Handle("/enrollments/waitlist", httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Waitlist),
+		decodeWaitlist,
+		encodeResponse,
+		opts...,
+	)).Methods("GET")
+
+	r.Handle("/enrollments/capacity", httptransport.NewServer(
+		endpoint.Endpoint(endpoints.SetCapacity),
+		decodeSetCapacity,
+		encodeResponse,
+		opts...,
+	)).Methods("PUT")
+
+	r.Handle("/enrollments/{id}", httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Get),
+		decodeGetEnrollment,
+		encodeResponse,
+		opts...,
+	)).Methods("GET")
+
 	r.Handle("/enrollments/{id}", httptransport.NewServer(
 		endpoint.Endpoint(endpoints.Update),
 		decodeUpdateEnrollment,
@@ -42,6 +92,13 @@ func NewEnrollmentHTTPServer(ctx context.Context, endpoints enrollment.Endpoints
 		opts...,
 	)).Methods("PATCH")
 
+	r.Handle("/enrollments/{id}", httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Delete),
+		decodeDeleteEnrollment,
+		encodeResponse,
+		opts...,
+	)).Methods("DELETE")
+
 	return r
 }
 
@@ -53,9 +110,21 @@ func decodeCreateEnrollment(_ context.Context, r *http.Request) (interface{}, er
 		return nil, response.BadRequest(fmt.Sprintf("invalid request format: %v", err.Error()))
 	}
 
+	createReq.IdempotencyKey = r.Header.Get("Idempotency-Key")
+
 	return createReq, nil
 }
 
+func decodeCreateBulkEnrollment(_ context.Context, r *http.Request) (interface{}, error) {
+	var req enrollment.CreateBulkReq
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, response.BadRequest(fmt.Sprintf("invalid request format: %v", err.Error()))
+	}
+
+	return req, nil
+}
+
 func decodeGetAllEnrollment(_ context.Context, r *http.Request) (interface{}, error) {
 
 	v := r.URL.Query()
@@ -63,11 +132,91 @@ func decodeGetAllEnrollment(_ context.Context, r *http.Request) (interface{}, er
 	limit, _ := strconv.Atoi(v.Get("limit"))
 	page, _ := strconv.Atoi(v.Get("page"))
 
+	createdFrom, err := parseDate(v.Get("created_from"), false)
+	if err != nil {
+		return nil, response.BadRequest(fmt.Sprintf("invalid created_from: %v", err.Error()))
+	}
+
+	createdTo, err := parseDate(v.Get("created_to"), true)
+	if err != nil {
+		return nil, response.BadRequest(fmt.Sprintf("invalid created_to: %v", err.Error()))
+	}
+
 	req := enrollment.GetAllReq{
-		UserID:   v.Get("user_id"),
-		CourseID: v.Get("course_id"),
-		Limit:    limit,
-		Page:     page,
+		UserIDs:        splitList(v.Get("user_id")),
+		CourseIDs:      splitList(v.Get("course_id")),
+		Statuses:       splitList(v.Get("status")),
+		CreatedFrom:    createdFrom,
+		CreatedTo:      createdTo,
+		IncludeDeleted: v.Get("include_deleted") == "true",
+		Sort:           v.Get("sort"),
+		Limit:          limit,
+		Page:           page,
+	}
+
+	if v.Has("cursor") {
+		cursor := v.Get("cursor")
+		req.Cursor = &cursor
+	}
+
+	return req, nil
+}
+
+// splitList parses a comma separated query value, ignoring empty items.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseDate accepts RFC 3339 timestamps or plain dates. A plain date used as
+// the end of a range covers the whole day.
+func parseDate(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func decodeGetEnrollment(_ context.Context, r *http.Request) (interface{}, error) {
+	path := mux.Vars(r)
+	req := enrollment.GetReq{
+		ID: path["id"],
+	}
+
+	return req, nil
+}
+
+func decodeWaitlist(_ context.Context, r *http.Request) (interface{}, error) {
+	req := enrollment.WaitlistReq{
+		CourseID: r.URL.Query().Get("course_id"),
+	}
+
+	return req, nil
+}
+
+func decodeSetCapacity(_ context.Context, r *http.Request) (interface{}, error) {
+	var req enrollment.SetCapacityReq
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, response.BadRequest(fmt.Sprintf("invalid request format: %v", err.Error()))
 	}
 
 	return req, nil
@@ -86,6 +235,19 @@ func decodeUpdateEnrollment(_ context.Context, r *http.Request) (interface{}, er
 	return req, nil
 }
 
+func decodeDeleteEnrollment(_ context.Context, r *http.Request) (interface{}, error) {
+	path := mux.Vars(r)
+	req := enrollment.DeleteReq{
+		ID: path["id"],
+	}
+
+	return req, nil
+}
+
+func decodeHealth(_ context.Context, _ *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
 func encodeResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
 	r := resp.(response.Response)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -96,6 +258,9 @@ func encodeResponse(ctx context.Context, w http.ResponseWriter, resp interface{}
 func encodedError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	resp := err.(response.Response)
+	if resp.StatusCode() == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="enrollment"`)
+	}
 	w.WriteHeader(resp.StatusCode())
 	_ = json.NewEncoder(w).Encode(resp)
 
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+type requestStartKey struct{}
+
+// InstrumentingOptions count and time the requests of the servers, labeled by
+// the route template, method and status code.
+func InstrumentingOptions(requests metrics.Counter, latency metrics.Histogram) []httptransport.ServerOption {
+	return []httptransport.ServerOption{
+		httptransport.ServerBefore(func(ctx context.Context, _ *http.Request) context.Context {
+			return context.WithValue(ctx, requestStartKey{}, time.Now())
+		}),
+		httptransport.ServerFinalizer(func(ctx context.Context, code int, r *http.Request) {
+			labels := []string{"route", routeTemplate(r), "method", r.Method, "code", strconv.Itoa(code)}
+			requests.With(labels...).Add(1)
+			if begin, ok := ctx.Value(requestStartKey{}).(time.Time); ok {
+				latency.With(labels...).Observe(time.Since(begin).Seconds())
+			}
+		}),
+	}
+}
+
+// routeTemplate returns the template of the route matched by the router, such
+// as /enrollments/{id}, so the requests of a route share the same labels.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return r.URL.Path
+}
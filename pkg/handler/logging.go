@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/logging"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// LoggingOptions tag the lines logged during a request with its route and log
+// every request once it is answered. The request ID is tagged by
+// requestid.Middleware.
+func LoggingOptions(log *slog.Logger) []httptransport.ServerOption {
+	return []httptransport.ServerOption{
+		httptransport.ServerBefore(func(ctx context.Context, r *http.Request) context.Context {
+			ctx = logging.With(ctx, logging.RouteKey, routeTemplate(r))
+			return context.WithValue(ctx, requestStartKey{}, time.Now())
+		}),
+		httptransport.ServerFinalizer(func(ctx context.Context, code int, r *http.Request) {
+			level := slog.LevelInfo
+			if code >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			args := []any{"method", r.Method, "path", r.URL.Path, "status", code}
+			if begin, ok := ctx.Value(requestStartKey{}).(time.Time); ok {
+				args = append(args, "duration_ms", float64(time.Since(begin).Microseconds())/1000)
+			}
+			log.Log(ctx, level, "request handled", args...)
+		}),
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/JuD4Mo/go_api_web_enrollment/pkg/handler"
+
+// TracingOptions start a server span for every request, continuing the trace
+// of the W3C trace context headers when the caller sends them.
+func TracingOptions() []httptransport.ServerOption {
+	return []httptransport.ServerOption{
+		httptransport.ServerBefore(func(ctx context.Context, r *http.Request) context.Context {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+
+			route := routeTemplate(r)
+			ctx, _ = otel.Tracer(instrumentationName).Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", route),
+					attribute.String("url.path", r.URL.Path),
+				),
+			)
+			return ctx
+		}),
+		httptransport.ServerFinalizer(func(ctx context.Context, code int, _ *http.Request) {
+			span := trace.SpanFromContext(ctx)
+			span.SetAttributes(attribute.Int("http.response.status_code", code))
+			if code >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(code))
+			}
+			span.End()
+		}),
+	}
+}
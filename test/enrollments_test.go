@@ -56,6 +56,46 @@ func TestEnrollments(t *testing.T) {
 		assert.Equal(t, dataCreated.UserID, dataGetAll[0].UserID)
 		assert.Equal(t, dataCreated.CourseID, dataGetAll[0].CourseID)
 		assert.Equal(t, domain.Pending, dataGetAll[0].Status)
+
+		resp = cli.Post("/enrollments", bodyReq)
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("get an enrollment by id", func(t *testing.T) {
+		bodyRequest := enrollment.CreateReq{
+			UserId:   "33-test",
+			CourseId: "44-test",
+		}
+
+		resp := cli.Post("/enrollments", bodyRequest)
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		dataCreated := domain.Enrollment{}
+		dRespCreated := dataResponse{Data: &dataCreated}
+		err := resp.FillUp(&dRespCreated)
+		assert.Nil(t, err)
+
+		resp = cli.Get("/enrollments/" + dataCreated.ID)
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		dataGet := domain.Enrollment{}
+		dRespGet := dataResponse{Data: &dataGet}
+		err = resp.FillUp(&dRespGet)
+		assert.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, dRespGet.Status)
+		assert.Equal(t, "success", dRespGet.Message)
+		assert.Equal(t, dataCreated.ID, dataGet.ID)
+		assert.Equal(t, dataCreated.UserID, dataGet.UserID)
+		assert.Equal(t, dataCreated.CourseID, dataGet.CourseID)
+		assert.Equal(t, domain.Pending, dataGet.Status)
+
+		resp = cli.Get("/enrollments/unknown-id")
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 	})
 
 	t.Run("update an enrollment", func(t *testing.T) {
@@ -96,4 +136,44 @@ func TestEnrollments(t *testing.T) {
 		assert.Equal(t, dataCreated.CourseID, dataGetAll[0].CourseID)
 		assert.Equal(t, domain.Active, dataGetAll[0].Status)
 	})
+
+	t.Run("withdraw an enrollment", func(t *testing.T) {
+		bodyRequest := enrollment.CreateReq{
+			UserId:   "55-test",
+			CourseId: "66-test",
+		}
+
+		resp := cli.Post("/enrollments", bodyRequest)
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		dataCreated := domain.Enrollment{}
+		dRespCreated := dataResponse{Data: &dataCreated}
+		err := resp.FillUp(&dRespCreated)
+		assert.Nil(t, err)
+
+		resp = cli.Delete("/enrollments/" + dataCreated.ID)
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = cli.Get("/enrollments/" + dataCreated.ID)
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp = cli.Get("/enrollments?user_id=" + dataCreated.UserID + "&course_id=" + dataCreated.CourseID)
+		assert.Nil(t, resp.Err)
+
+		var dataGetAll []domain.Enrollment
+		err = resp.FillUp(&dataResponse{Data: &dataGetAll})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(dataGetAll))
+
+		resp = cli.Get("/enrollments?include_deleted=true&user_id=" + dataCreated.UserID + "&course_id=" + dataCreated.CourseID)
+		assert.Nil(t, resp.Err)
+
+		err = resp.FillUp(&dataResponse{Data: &dataGetAll})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(dataGetAll))
+		assert.Equal(t, enrollment.Withdrawn, dataGetAll[0].Status)
+	})
 }
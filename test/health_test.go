@@ -0,0 +1,27 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	t.Run("should report the process alive", func(t *testing.T) {
+		resp := cli.Get("/healthz")
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should report the service ready", func(t *testing.T) {
+		resp := cli.Get("/readyz")
+		assert.Nil(t, resp.Err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		data := map[string]interface{}{}
+		err := resp.FillUp(&dataResponse{Data: &data})
+		assert.Nil(t, err)
+		assert.Equal(t, "up", data["status"])
+	})
+}
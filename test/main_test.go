@@ -3,8 +3,8 @@ package test
 import (
 	"context"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -13,33 +13,47 @@ import (
 
 	"github.com/JuD4Mo/go_api_web_domain/domain"
 	"github.com/JuD4Mo/go_api_web_enrollment/internal/enrollment"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/health"
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/requestid"
 	"github.com/JuD4Mo/go_api_web_enrollment/pkg/bootstrap"
 	"github.com/JuD4Mo/go_api_web_enrollment/pkg/handler"
 	courseSdkMock "github.com/JuD4Mo/go_api_web_sdk/course/mock"
 	userSdkMock "github.com/JuD4Mo/go_api_web_sdk/user/mock"
 	"github.com/joho/godotenv"
 	"github.com/ncostamagna/go_http_client/client"
+	"gorm.io/gorm"
 )
 
-var cli client.Transport
+var (
+	cli     client.Transport
+	baseURL string
+)
 
 func TestMain(m *testing.M) {
 	//Cargamos las variables de entorno que están en el archivo .env por medio del package godotenv
 	_ = godotenv.Load("../.env")
 
 	//Instanciamos un logger propio
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.DiscardHandler)
+
+	//Con ENROLLMENT_REPOSITORY=memory las pruebas corren sin la base de datos de docker
+	var enrollRepo enrollment.Repository
+	var tx *gorm.DB
+	if os.Getenv("ENROLLMENT_REPOSITORY") == "memory" {
+		enrollRepo = enrollment.NewMemoryRepo(l)
+	} else {
+		db, err := bootstrap.DBConnection(l)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	db, err := bootstrap.DBConnection()
-	if err != nil {
-		l.Fatal(err)
+		tx = db.Begin()
+		enrollRepo = enrollment.NewRepo(tx, l)
 	}
 
-	tx := db.Begin()
-
 	pagLimDef := os.Getenv("PAGINATOR_LIMIT_DEFAULT")
 	if pagLimDef == "" {
-		l.Fatal("paginator limit default is required")
+		log.Fatal("paginator limit default is required")
 	}
 
 	userSdk := &userSdkMock.UserSdkMock{
@@ -56,16 +70,17 @@ func TestMain(m *testing.M) {
 
 	ctx := context.Background()
 
-	enrollRepo := enrollment.NewRepo(tx, l)
-	enrollService := enrollment.NewService(l, enrollRepo, userSdk, courseSdk)
-	h := handler.NewEnrollmentHTTPServer(ctx, enrollment.MakeEndpoints(enrollService, enrollment.Config{LimitPage: pagLimDef}))
+	enrollService := enrollment.NewService(l, enrollRepo, userSdk, courseSdk, 0)
+	h := handler.NewEnrollmentHTTPServer(ctx, enrollment.MakeEndpoints(enrollService, enrollment.Config{LimitPage: pagLimDef}),
+		health.MakeEndpoints(health.NewService(l, time.Second)))
 
 	port := os.Getenv("PORT")
 	address := fmt.Sprintf("127.0.0.1:%s", port)
-	cli = client.New(nil, "http://"+address, 0, false)
+	baseURL = "http://" + address
+	cli = client.New(nil, baseURL, 0, false)
 	//Se crea una instancia de un servidor
 	srv := &http.Server{
-		Handler:      accessControl(h),
+		Handler:      requestid.Middleware(accessControl(h)),
 		Addr:         address,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 4 * time.Second,
@@ -73,7 +88,7 @@ func TestMain(m *testing.M) {
 
 	errCh := make(chan error, 1)
 	go func() {
-		l.Println("listen in", address)
+		l.Info("listening", "address", address)
 		errCh <- srv.ListenAndServe()
 	}()
 
@@ -86,26 +101,29 @@ func TestMain(m *testing.M) {
 			break
 		}
 		if time.Now().After(deadline) {
-			l.Fatal("server did not start in time: ", err)
+			log.Fatal("server did not start in time: ", err)
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
 
 	r := m.Run()
 
-	err = srv.Shutdown(context.Background())
+	err := srv.Shutdown(context.Background())
 	if err != nil {
-		l.Println(err)
+		l.Error("shutting down", "error", err)
+	}
+	if tx != nil {
+		tx.Rollback()
 	}
-	tx.Rollback()
 	os.Exit(r)
 }
 
 func accessControl(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST, PATCH, OPTIONS, DELETE, HEAD")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Modified-Since,Keep-Alive,Origin,User-Agent,X-Requested-With")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST, PATCH, PUT, OPTIONS, DELETE, HEAD")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Modified-Since,Keep-Alive,Origin,User-Agent,X-Requested-With,Idempotency-Key,traceparent,tracestate,X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/JuD4Mo/go_api_web_enrollment/internal/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	get := func(t *testing.T, path, id string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, baseURL+path, nil)
+		require.NoError(t, err)
+		if id != "" {
+			req.Header.Set(requestid.Header, id)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	t.Run("should echo the request id of the caller", func(t *testing.T) {
+		resp := get(t, "/healthz", "caller-id")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "caller-id", resp.Header.Get(requestid.Header))
+	})
+
+	t.Run("should echo the request id on errors", func(t *testing.T) {
+		resp := get(t, "/enrollments/unknown-id", "caller-id")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "caller-id", resp.Header.Get(requestid.Header))
+	})
+
+	t.Run("should generate a request id", func(t *testing.T) {
+		resp := get(t, "/healthz", "")
+		assert.Len(t, resp.Header.Get(requestid.Header), 36)
+	})
+}